@@ -0,0 +1,33 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/fetcher"
+
+	//
+	"github.com/twiny/flog"
+	"github.com/twiny/wbot"
+)
+
+// botLog: a wbot.Logger writing crawl reports worth keeping to the log.
+type botLog struct {
+	log *flog.Logger
+}
+
+// Send
+func (b *botLog) Send(rep wbot.Report) {
+	if errors.Is(rep.Err, fetcher.ErrDisallowed) {
+		b.log.Info("disallowed by robots.txt", map[string]string{
+			"url":   rep.RequestURL,
+			"depth": strconv.Itoa(int(rep.Depth)),
+		})
+	}
+}
+
+// Close: the log is closed by the spider.
+func (b *botLog) Close() error {
+	return nil
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+	"github.com/twiny/spidy/v2/internal/service/cache"
+)
+
+// export formats
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// csv export columns
+var recordColumns = []string{"name", "status", "backend", "checked_at", "expires_at", "error", "pages", "hosts", "first_seen", "last_seen", "anchors", "site", "site_detail"}
+
+// CacheFilter selects records by domain pattern (e.g. *.io) and status.
+type CacheFilter struct {
+	Pattern string
+	Status  string
+}
+
+// match
+func (f CacheFilter) match(rec *spider.Record) bool {
+	if f.Status != "" && rec.Status != f.Status {
+		return false
+	}
+	if f.Pattern != "" {
+		if ok, _ := path.Match(f.Pattern, rec.Name); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// OpenCache opens the store configured in setting.
+func OpenCache(setting *spider.Setting) (*cache.Cache, error) {
+	return cache.New(cache.Config{
+		Driver: setting.Store.Driver,
+		Path:   setting.Store.Path,
+		Size:   setting.Store.Size,
+		TTL:    setting.Store.TTL,
+		TTLs:   setting.Store.TTLs,
+
+		Bloom:     setting.Store.Bloom,
+		BloomSize: setting.Store.BloomSize,
+		BloomFP:   setting.Store.BloomFP,
+		HotSize:   setting.Store.HotSize,
+		VisitTTL:  setting.Store.VisitTTL,
+		HTTPTTL:   setting.Store.HTTPTTL,
+	})
+}
+
+// CacheList prints matching records with their status and age.
+func CacheList(c *cache.Cache, f CacheFilter, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tSTATUS\tAGE\tPAGES\tERROR")
+
+	now := time.Now()
+	if err := c.Range(func(rec *spider.Record) error {
+		if !f.match(rec) {
+			return nil
+		}
+
+		age := "-"
+		if !rec.CheckedAt.IsZero() {
+			age = now.Sub(rec.CheckedAt).Round(time.Second).String()
+		}
+
+		status := rec.Status
+		if status == "" {
+			status = "-"
+		}
+
+		_, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", rec.Name, status, age, rec.Referrers.Pages, rec.Err)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
+
+// CacheGet prints the record of a single domain.
+func CacheGet(c *cache.Cache, name string, w io.Writer) error {
+	rec, err := c.Get(strings.ToLower(name))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rec)
+}
+
+// CacheExport writes matching records as JSONL or CSV.
+func CacheExport(c *cache.Cache, f CacheFilter, format string, w io.Writer) error {
+	switch format {
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		return c.Range(func(rec *spider.Record) error {
+			if !f.match(rec) {
+				return nil
+			}
+			return enc.Encode(rec)
+		})
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(recordColumns); err != nil {
+			return err
+		}
+		if err := c.Range(func(rec *spider.Record) error {
+			if !f.match(rec) {
+				return nil
+			}
+			return cw.Write(recordRow(rec))
+		}); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// CacheImport reads records exported by CacheExport,
+// it returns the number of records imported.
+func CacheImport(c *cache.Cache, format string, r io.Reader) (int, error) {
+	var n int
+	store := func(rec *spider.Record) error {
+		ok, err := c.Import(rec)
+		if ok {
+			n++
+		}
+		return err
+	}
+
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			var rec spider.Record
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return n, err
+			}
+			if err := store(&rec); err != nil {
+				return n, err
+			}
+		}
+		return n, scanner.Err()
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err != nil {
+			return n, err
+		}
+		col := columnIndex(header)
+
+		for {
+			row, err := cr.Read()
+			if err == io.EOF {
+				return n, nil
+			}
+			if err != nil {
+				return n, err
+			}
+
+			rec, err := parseRecordRow(row, col)
+			if err != nil {
+				return n, err
+			}
+			if err := store(rec); err != nil {
+				return n, err
+			}
+		}
+	default:
+		return n, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// CachePurge deletes matching records, it returns the number deleted.
+func CachePurge(c *cache.Cache, f CacheFilter) (int, error) {
+	if f.Pattern == "" && f.Status == "" {
+		return 0, fmt.Errorf("a pattern or a status is required")
+	}
+
+	var names []string
+	if err := c.Range(func(rec *spider.Record) error {
+		if f.match(rec) {
+			names = append(names, rec.Name)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for i, name := range names {
+		if err := c.Delete(name); err != nil {
+			return i, err
+		}
+	}
+
+	return len(names), nil
+}
+
+// recordRow
+func recordRow(rec *spider.Record) []string {
+	return []string{
+		rec.Name,
+		rec.Status,
+		rec.Backend,
+		spider.FormatTime(rec.CheckedAt),
+		spider.FormatTime(rec.ExpiresAt),
+		rec.Err,
+		strconv.Itoa(rec.Referrers.Pages),
+		strings.Join(rec.Referrers.Hosts, " "),
+		spider.FormatTime(rec.Referrers.FirstSeen),
+		spider.FormatTime(rec.Referrers.LastSeen),
+		spider.JoinAnchors(rec.Referrers.Anchors),
+		rec.Site,
+		rec.SiteDetail,
+	}
+}
+
+// parseRecordRow
+func parseRecordRow(row []string, col map[string]int) (*spider.Record, error) {
+	rec := &spider.Record{
+		Name:       field(row, col, "name"),
+		Status:     field(row, col, "status"),
+		Backend:    field(row, col, "backend"),
+		Err:        field(row, col, "error"),
+		Site:       field(row, col, "site"),
+		SiteDetail: field(row, col, "site_detail"),
+	}
+
+	if rec.Name == "" {
+		return nil, fmt.Errorf("record without name")
+	}
+
+	var err error
+	for _, t := range []struct {
+		column string
+		value  *time.Time
+	}{
+		{"checked_at", &rec.CheckedAt},
+		{"expires_at", &rec.ExpiresAt},
+		{"first_seen", &rec.Referrers.FirstSeen},
+		{"last_seen", &rec.Referrers.LastSeen},
+	} {
+		if *t.value, err = parseTime(field(row, col, t.column)); err != nil {
+			return nil, fmt.Errorf("%s: %w", rec.Name, err)
+		}
+	}
+
+	if pages := field(row, col, "pages"); pages != "" {
+		if rec.Referrers.Pages, err = strconv.Atoi(pages); err != nil {
+			return nil, fmt.Errorf("%s: %w", rec.Name, err)
+		}
+	}
+
+	rec.Referrers.Hosts = strings.Fields(field(row, col, "hosts"))
+	rec.Referrers.Anchors = spider.SplitAnchors(field(row, col, "anchors"))
+
+	return rec, nil
+}
+
+// parseTime
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+	"github.com/twiny/spidy/v2/internal/pkg/priority"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"github.com/twiny/wbot"
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxYields: fetched pages whose yield is kept to score their links.
+const maxYields = 100000
+
+// yield: the domains of a fetched page, kept until handled.
+type yield struct {
+	page    priority.Page
+	domains []spider.Domain
+}
+
+// yields records what each fetched page yielded, wbot queues the links of
+// a page right after fetching it so they are scored by their parent.
+type yields struct {
+	pages   *lru.Cache[string, yield]
+	store   spider.Storage
+	tlds    map[string]bool
+	extract map[int]bool // statuses of pages whose domains are extracted
+	find    func(body []byte) []spider.Domain
+}
+
+// newYields: find extracts the domains of a page.
+func newYields(store spider.Storage, tlds map[string]bool, extract map[int]bool, find func(body []byte) []spider.Domain) *yields {
+	return &yields{
+		pages:   lru.New[string, yield](maxYields),
+		store:   store,
+		tlds:    tlds,
+		extract: extract,
+		find:    find,
+	}
+}
+
+// record the external domains of a page and how many are new.
+func (y *yields) record(link string, res wbot.Response) priority.Page {
+	if !y.extract[res.Status] {
+		return priority.Page{}
+	}
+
+	own, _ := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(res.URL.Hostname()))
+
+	var p priority.Page
+	domains := y.find(res.Body)
+	for _, d := range domains {
+		root := d.Name + "." + d.TLD
+		if root == own {
+			continue
+		}
+		if len(y.tlds) > 0 && !y.tlds[d.TLD] {
+			continue
+		}
+
+		p.Domains++
+		if _, err := y.store.Get(root); errors.Is(err, spider.ErrNotFound) {
+			p.New++
+		}
+	}
+
+	y.pages.Add(link, yield{page: p, domains: domains})
+
+	return p
+}
+
+// parent returns the yield of the page that linked req.
+func (y *yields) parent(req wbot.Request) priority.Page {
+	if req.Param.Referer == "" {
+		return priority.Page{}
+	}
+	v, _ := y.pages.Get(req.Param.Referer)
+	return v.page
+}
+
+// domains of a crawled page, found when it was fetched if possible.
+func (y *yields) domains(res wbot.Response) []spider.Domain {
+	link := res.URL.String()
+	if v, found := y.pages.Get(link); found && v.domains != nil {
+		y.pages.Add(link, yield{page: v.page})
+		return v.domains
+	}
+	return y.find(res.Body)
+}
+
+// yieldFetcher records the yield of each fetched page.
+type yieldFetcher struct {
+	wbot.Fetcher
+	yields *yields
+	traps  *traps // optional
+}
+
+// Fetch
+func (f *yieldFetcher) Fetch(req wbot.Request) (wbot.Response, error) {
+	res, err := f.Fetcher.Fetch(req)
+	if err != nil || res.Status == http.StatusNotModified {
+		return res, err
+	}
+
+	p := f.yields.record(req.URL.String(), res)
+	if f.traps != nil {
+		f.traps.page(req.URL, res.Body, p.New)
+	}
+
+	return res, nil
+}
+
+// yieldScorer: the default frontier.Scorer.
+type yieldScorer struct {
+	score  *priority.Scorer
+	yields *yields
+	run    *run
+}
+
+// Score
+func (s *yieldScorer) Score(req wbot.Request) float64 {
+	return s.score.Score(priority.Input{
+		URL:       req.URL,
+		Depth:     req.Depth,
+		Parent:    s.yields.parent(req),
+		HostPages: s.run.hostPages(strings.ToLower(req.URL.Hostname())),
+	})
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/guard"
+	"github.com/twiny/spidy/v2/internal/pkg/proxy"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"github.com/twiny/wbot"
+)
+
+// proxies: crawler.proxies and the proxies file.
+func proxies(setting *spider.Setting) ([]string, error) {
+	list := append([]string{}, setting.Crawler.Proxies...)
+	if setting.Proxy.File == "" {
+		return list, nil
+	}
+
+	found, err := proxy.ReadFile(setting.Proxy.File)
+	if err != nil {
+		return nil, err
+	}
+	return append(list, found...), nil
+}
+
+// proxyFetcher sends each request through a proxy of the pool
+// and reports how the proxy did.
+type proxyFetcher struct {
+	wbot.Fetcher
+	pool *proxy.Pool
+}
+
+// Fetch
+func (f *proxyFetcher) Fetch(req wbot.Request) (wbot.Response, error) {
+	req.Param.Proxy = f.pool.Pick(strings.ToLower(req.URL.Hostname()))
+	if req.Param.Proxy == "" {
+		return f.Fetcher.Fetch(req)
+	}
+
+	res, err := f.Fetcher.Fetch(req)
+	switch {
+	case err != nil && proxyFault(err):
+		f.pool.Report(req.Param.Proxy, err)
+	case err != nil:
+		// not sent or failed on the target side, e.g. unknown
+		// host, refused or slow site: says nothing of the proxy
+	case res.Status == http.StatusProxyAuthRequired:
+		// only a proxy asks for proxy credentials, other
+		// statuses come from the target
+		f.pool.Report(req.Param.Proxy, errors.New(http.StatusText(res.Status)))
+	default:
+		f.pool.Report(req.Param.Proxy, nil)
+	}
+
+	return res, err
+}
+
+// proxyFault reports whether a fetch failed because of the proxy rather
+// than the target: the proxy could not be reached, or refused the tunnel
+// with 407 or a 5xx.
+func proxyFault(err error) bool {
+	if errors.Is(err, guard.ErrBlocked) {
+		return false
+	}
+
+	var op *net.OpError
+	if errors.As(err, &op) {
+		switch {
+		case op.Op == "proxyconnect":
+			// dial or tls handshake with the proxy
+			return true
+		case strings.HasPrefix(op.Op, "socks"):
+			return !socksTarget(op.Err)
+		}
+	}
+
+	// a refused CONNECT fails with the status text of the proxy
+	cause := err
+	for errors.Unwrap(cause) != nil {
+		cause = errors.Unwrap(cause)
+	}
+	return connectRefused(cause.Error())
+}
+
+// connectRefused reports whether text is the status text of a CONNECT
+// answered with 407 or a 5xx.
+func connectRefused(text string) bool {
+	if strings.EqualFold(text, http.StatusText(http.StatusProxyAuthRequired)) {
+		return true
+	}
+
+	for status := 500; status < 600; status++ {
+		if st := http.StatusText(status); st != "" && strings.EqualFold(text, st) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// socksTarget: replies of a socks proxy that could not reach the target.
+func socksTarget(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch err.Error() {
+	case "network unreachable", "host unreachable", "connection refused", "TTL expired":
+		return true
+	}
+	return false
+}
+
+// proxyLoop reloads the proxies file when it changes.
+func (s *Spider) proxyLoop() {
+	defer s.loops.Done()
+
+	ticker := time.NewTicker(s.setting.Proxy.Reload)
+	defer ticker.Stop()
+
+	var modified time.Time
+	if fi, err := os.Stat(s.setting.Proxy.File); err == nil {
+		modified = fi.ModTime()
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(s.setting.Proxy.File)
+		if err != nil || !fi.ModTime().After(modified) {
+			continue
+		}
+		modified = fi.ModTime()
+
+		list, err := proxies(s.setting)
+		if err != nil {
+			s.log.Error(err.Error(), map[string]string{"file": s.setting.Proxy.File})
+			continue
+		}
+		s.proxies.Set(list)
+		s.fetch.Proxies(list)
+
+		s.log.Info("proxies reloaded", map[string]string{
+			"file":    s.setting.Proxy.File,
+			"proxies": strconv.Itoa(len(list)),
+		})
+	}
+}
+
+// proxyStats logs and prints the counters of each proxy.
+func (s *Spider) proxyStats() {
+	for _, st := range s.proxies.Stats() {
+		if st.Requests == 0 {
+			continue
+		}
+
+		rate := strconv.FormatFloat(float64(st.Errors)*100/float64(st.Requests), 'f', 1, 64) + "%"
+
+		s.log.Info("proxy", map[string]string{
+			"proxy":     st.Proxy,
+			"requests":  strconv.FormatUint(st.Requests, 10),
+			"errors":    rate,
+			"evictions": strconv.FormatUint(st.Evictions, 10),
+			"evicted":   strconv.FormatBool(st.Evicted),
+		})
+
+		fmt.Printf("[Spidy] == proxy: %s - %d requests, %s errors, %d evictions\n",
+			st.Proxy, st.Requests, rate, st.Evictions)
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/score"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+)
+
+// legacy result files have no header
+var legacyColumns = []string{"domain", "status"}
+
+// referrerColumns: rendered again from the referrers of the store.
+var referrerColumns = []string{"pages", "hosts", "first_seen", "last_seen", "anchors"}
+
+// Rank sorts the result file fp by score, highest first, and writes it to w.
+// a domain has one row, written when it was checked: its referrer columns
+// and score are updated from the stats merged in the store since. rows
+// without a score column are scored using the settings. if status is not
+// empty only rows with that status are kept.
+func Rank(setting *spider.Setting, fp, status string, w io.Writer) error {
+	header, rows, err := readResults(fp)
+	if err != nil {
+		return err
+	}
+
+	col := columnIndex(header)
+
+	// referrer stats merged since the rows were written
+	refs := storedReferrers(setting, rows, col)
+	for i, r := range refs {
+		d := spider.Domain{Referrers: r}
+		for _, name := range referrerColumns {
+			if j, found := col[name]; found && j < len(rows[i]) {
+				rows[i][j] = spider.Columns[name](d)
+			}
+		}
+	}
+
+	// compute missing and outdated scores
+	_, scored := col["score"]
+	if !scored || len(refs) > 0 {
+		scorer, err := score.NewScorer(setting.Score.Words, setting.Score.TLDs, score.ParseWeights(setting.Score.Weights))
+		if err != nil {
+			return err
+		}
+
+		if !scored {
+			header = append(header, "score")
+			col["score"] = len(header) - 1
+		}
+
+		for i, row := range rows {
+			r, stored := refs[i]
+			if scored && !stored {
+				continue
+			}
+
+			value := ""
+			if name, tld, ok := rowDomain(row, col); ok {
+				pages := r.Pages
+				if !stored {
+					pages, _ = strconv.Atoi(field(row, col, "pages"))
+				}
+				value = strconv.FormatFloat(scorer.Score(name, tld, pages), 'f', 2, 64)
+			}
+
+			if scored {
+				if j := col["score"]; j < len(row) {
+					row[j] = value
+				}
+				continue
+			}
+			rows[i] = append(row, value)
+		}
+	}
+
+	// filter by status
+	if status != "" {
+		if _, found := col["status"]; !found {
+			return fmt.Errorf("%s: no status column", fp)
+		}
+
+		kept := rows[:0]
+		for _, row := range rows {
+			if field(row, col, "status") == status {
+				kept = append(kept, row)
+			}
+		}
+		rows = kept
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(field(rows[i], col, "score"), 64)
+		b, _ := strconv.ParseFloat(field(rows[j], col, "score"), 64)
+		return a > b
+	})
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// storedReferrers returns the referrers of the domains of rows found in
+// the store, by row. the result file is used alone if the store cannot
+// be opened, e.g. while a crawl holds it.
+func storedReferrers(setting *spider.Setting, rows [][]string, col map[string]int) map[int]spider.Referrers {
+	store, err := OpenCache(setting)
+	if err != nil {
+		log.Printf("rank: referrer stats of the result file are used, store: %v", err)
+		return nil
+	}
+	defer store.Close()
+
+	refs := make(map[int]spider.Referrers)
+	for i, row := range rows {
+		name, tld, ok := rowDomain(row, col)
+		if !ok {
+			continue
+		}
+
+		rec, err := store.Get(name + "." + tld)
+		if err != nil || rec.Referrers.Pages == 0 {
+			continue
+		}
+		refs[i] = rec.Referrers
+	}
+
+	return refs
+}
+
+// readResults reads a result file, gzip files are supported.
+func readResults(fp string) ([]string, [][]string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(fp, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("%s: empty result file", fp)
+	}
+
+	// header is written once per file
+	header := append([]string{}, legacyColumns...)
+	if spider.ValidColumns(rows[0]) == nil {
+		header, rows = rows[0], rows[1:]
+	}
+
+	// short rows are padded so appended columns keep their place
+	for i, row := range rows {
+		if len(row) < len(header) {
+			rows[i] = append(row, make([]string, len(header)-len(row))...)
+		}
+	}
+
+	return header, rows, nil
+}
+
+// columnIndex
+func columnIndex(header []string) map[string]int {
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	return col
+}
+
+// field
+func field(row []string, col map[string]int, name string) string {
+	i, found := col[name]
+	if !found || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+// rowDomain
+func rowDomain(row []string, col map[string]int) (string, string, bool) {
+	if name, tld := field(row, col, "name"), field(row, col, "tld"); name != "" && tld != "" {
+		return name, tld, true
+	}
+
+	domain := field(row, col, "domain")
+	i := strings.Index(domain, ".")
+	if i <= 0 {
+		return "", "", false
+	}
+
+	return domain[:i], domain[i+1:], true
+}
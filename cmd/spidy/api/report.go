@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/cache"
+)
+
+// report logs and prints crawl counters on close.
+func (s *Spider) report() {
+	if s.run.running() {
+		s.log.Info("run summary", s.run.summary())
+		fmt.Printf("[Spidy] == run: %s\n", s.run)
+	}
+
+	s.storeStats()
+	s.proxyStats()
+
+	if s.traps != nil {
+		s.traps.report()
+	}
+
+	if n := s.fetch.Disallowed(); n > 0 {
+		s.log.Info("robots.txt", map[string]string{
+			"disallowed": strconv.FormatUint(n, 10),
+		})
+		fmt.Printf("[Spidy] == robots.txt: %d urls disallowed\n", n)
+	}
+
+	if n := s.guard.Blocked(); n > 0 {
+		s.log.Info("guard", map[string]string{
+			"blocked": strconv.FormatUint(n, 10),
+		})
+		fmt.Printf("[Spidy] == guard: %d connections to reserved ips blocked\n", n)
+	}
+
+	if n := s.fetch.Retries(); n > 0 {
+		s.log.Info("retries", map[string]string{
+			"retries": strconv.FormatUint(n, 10),
+		})
+		fmt.Printf("[Spidy] == retries: %d fetches retried\n", n)
+	}
+
+	if n := s.fetch.NotModified(); n > 0 {
+		s.log.Info("http cache", map[string]string{
+			"not_modified": strconv.FormatUint(n, 10),
+		})
+		fmt.Printf("[Spidy] == http cache: %d pages not modified\n", n)
+	}
+}
+
+// storeStats logs and prints the lookup counters of the store.
+func (s *Spider) storeStats() {
+	c, ok := s.store.(*cache.Cache)
+	if !ok {
+		return
+	}
+
+	st := c.Stats()
+	if st.Lookups == 0 {
+		return
+	}
+
+	rate := func(n uint64) string {
+		return strconv.FormatFloat(float64(n)*100/float64(st.Lookups), 'f', 1, 64) + "%"
+	}
+
+	s.log.Info("store lookups", map[string]string{
+		"lookups":      strconv.FormatUint(st.Lookups, 10),
+		"hot_hits":     rate(st.HotHits),
+		"bloom_skips":  rate(st.BloomSkips),
+		"store_hits":   rate(st.StoreHits),
+		"store_misses": rate(st.StoreMisses),
+	})
+
+	fmt.Printf("[Spidy] == store: %d lookups - hot %s, bloom skipped %s, store hit %s, store miss %s\n",
+		st.Lookups, rate(st.HotHits), rate(st.BloomSkips), rate(st.StoreHits), rate(st.StoreMisses))
+}
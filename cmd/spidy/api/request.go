@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/cookies"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+	"github.com/twiny/spidy/v2/internal/service/fetcher"
+
+	//
+	"golang.org/x/net/publicsuffix"
+)
+
+// request: headers, cookies and credentials of page requests.
+func request(setting spider.RequestSetting) (fetcher.Request, error) {
+	req := fetcher.Request{
+		Header: header(setting.Headers),
+		Hosts:  make([]fetcher.HostRequest, 0, len(setting.Hosts)),
+	}
+
+	for _, h := range setting.Hosts {
+		req.Hosts = append(req.Hosts, fetcher.HostRequest{
+			Pattern:  h.Pattern,
+			Header:   header(h.Headers),
+			Username: h.Username,
+			Password: h.Password,
+			Token:    h.Token,
+		})
+	}
+
+	if !setting.Cookies && setting.CookiesFile == "" {
+		return req, nil
+	}
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return req, err
+	}
+
+	if setting.CookiesFile != "" {
+		if err := cookies.Load(jar, setting.CookiesFile); err != nil {
+			return req, err
+		}
+	}
+	req.Jar = jar
+
+	return req, nil
+}
+
+// header
+func header(m map[string]string) http.Header {
+	h := make(http.Header, len(m))
+	for k, v := range m {
+		h.Set(k, v)
+	}
+	return h
+}
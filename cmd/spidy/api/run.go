@@ -0,0 +1,195 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/hbyte"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+)
+
+// stop reasons
+const (
+	StopCompleted    = "completed"
+	StopInterrupted  = "interrupted"
+	StopMaxPages     = "max pages"
+	StopMaxBytes     = "max bytes"
+	StopMaxRuntime   = "max runtime"
+	StopMaxAvailable = "max available"
+)
+
+// run: counters and budgets of a crawl.
+type run struct {
+	mu        *sync.Mutex
+	budget    spider.BudgetSetting
+	started   time.Time // zero until the crawl starts
+	pages     int
+	bytes     int64
+	checked   int
+	available int
+	hosts     map[string]int // pages by host
+	taken     map[string]int // pages of the host budget, fetched or in flight
+	reason    string
+	stop      func() // stops the crawl gracefully
+}
+
+// newRun
+func newRun(budget spider.BudgetSetting, stop func()) *run {
+	return &run{
+		mu:     &sync.Mutex{},
+		budget: budget,
+		hosts:  map[string]int{},
+		taken:  map[string]int{},
+		stop:   stop,
+	}
+}
+
+// start the runtime budget.
+func (r *run) start() {
+	r.mu.Lock()
+	r.started = time.Now()
+	r.mu.Unlock()
+
+	if r.budget.MaxRuntime > 0 {
+		time.AfterFunc(r.budget.MaxRuntime, func() {
+			r.halt(StopMaxRuntime)
+		})
+	}
+}
+
+// page counts a fetched page.
+func (r *run) page(host string, size int) {
+	r.mu.Lock()
+	r.pages++
+	r.bytes += int64(size)
+	r.hosts[host]++
+	pages, bytes := r.pages, r.bytes
+	r.mu.Unlock()
+
+	switch {
+	case r.budget.MaxPages > 0 && pages >= r.budget.MaxPages:
+		r.halt(StopMaxPages)
+	case r.budget.MaxBytes > 0 && bytes >= r.budget.MaxBytes:
+		r.halt(StopMaxBytes)
+	}
+}
+
+// check counts a checked domain.
+func (r *run) check(available bool) {
+	r.mu.Lock()
+	r.checked++
+	if available {
+		r.available++
+	}
+	found := r.available
+	r.mu.Unlock()
+
+	if r.budget.MaxAvailable > 0 && found >= r.budget.MaxAvailable {
+		r.halt(StopMaxAvailable)
+	}
+}
+
+// allow reports whether host has pages left in its budget.
+func (r *run) allow(host string) bool {
+	if r.budget.MaxHostPages <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.taken[strings.ToLower(host)] < r.budget.MaxHostPages
+}
+
+// take a page of the budget of host before it is fetched, so requests
+// in flight count. it reports false if none is left.
+func (r *run) take(host string) bool {
+	if r.budget.MaxHostPages <= 0 {
+		return true
+	}
+
+	host = strings.ToLower(host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken[host] >= r.budget.MaxHostPages {
+		return false
+	}
+	r.taken[host]++
+
+	return true
+}
+
+// refund a page taken but not fetched.
+func (r *run) refund(host string) {
+	if r.budget.MaxHostPages <= 0 {
+		return
+	}
+
+	host = strings.ToLower(host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	decr(r.taken, host)
+}
+
+// hostPages: pages fetched from host.
+func (r *run) hostPages(host string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts[host]
+}
+
+// halt stops the crawl, the first reason is kept.
+func (r *run) halt(reason string) {
+	r.mu.Lock()
+	if r.reason != "" {
+		r.mu.Unlock()
+		return
+	}
+	r.reason = reason
+	r.mu.Unlock()
+
+	r.stop()
+}
+
+// running reports whether a crawl was started.
+func (r *run) running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.started.IsZero()
+}
+
+// summary
+func (r *run) summary() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reason := r.reason
+	if reason == "" {
+		reason = StopCompleted
+	}
+
+	return map[string]string{
+		"runtime":   time.Since(r.started).Round(time.Second).String(),
+		"pages":     strconv.Itoa(r.pages),
+		"hosts":     strconv.Itoa(len(r.hosts)),
+		"bytes":     hbyte.String(r.bytes),
+		"checked":   strconv.Itoa(r.checked),
+		"available": strconv.Itoa(r.available),
+		"stopped":   reason,
+	}
+}
+
+// String
+func (r *run) String() string {
+	sum := r.summary()
+	return fmt.Sprintf("%s, %s pages from %s hosts, %s, %s domains checked, %s available - stopped: %s",
+		sum["runtime"], sum["pages"], sum["hosts"], sum["bytes"], sum["checked"], sum["available"], sum["stopped"])
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/url"
+	"sync"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/frontier"
+
+	//
+	"github.com/twiny/wbot"
+)
+
+// lease ends the frontier lease of each dequeued request. for every request
+// wbot calls Store.Visited, then its filter and rate limiter, then
+// Fetcher.Fetch. the frontier drops at Enqueue what the wbot filter or max
+// depth would stop, so a dequeued request is either visited already, its
+// lease ends in Visited, or fetched, its lease ends when Fetch returns.
+//
+// a page of the host budget is taken as a request is dequeued, and given
+// back if it was visited already.
+//
+// the wbot limiter keeps a map by host that is not safe for concurrent use:
+// requests to hosts it knows go through it together, the first request to
+// a new host goes alone.
+type lease struct {
+	frontier *frontier.Frontier
+	run      *run
+	limiter  *sync.RWMutex
+	mu       *sync.Mutex
+	leased   map[string]int  // dequeued urls
+	alone    map[string]int  // urls holding the limiter alone
+	shared   map[string]int  // and together
+	known    map[string]bool // hosts in the wbot limiter
+}
+
+// newLease
+func newLease(f *frontier.Frontier, r *run) *lease {
+	return &lease{
+		frontier: f,
+		run:      r,
+		limiter:  &sync.RWMutex{},
+		mu:       &sync.Mutex{},
+		leased:   map[string]int{},
+		alone:    map[string]int{},
+		shared:   map[string]int{},
+		known:    map[string]bool{},
+	}
+}
+
+// take records the lease of a dequeued request, it reports false and
+// ends it if its host has no pages left in its budget.
+func (l *lease) take(req wbot.Request) bool {
+	if !l.run.take(req.URL.Hostname()) {
+		l.frontier.Done(req.URL.Hostname())
+		return false
+	}
+
+	l.mu.Lock()
+	l.leased[req.URL.String()]++
+	l.mu.Unlock()
+
+	return true
+}
+
+// release ends the lease of link, if it was dequeued.
+func (l *lease) release(link string) {
+	if host, ok := l.end(link); ok {
+		l.frontier.Done(host)
+	}
+}
+
+// skip ends the lease of link visited already, its page is given back
+// to the host budget.
+func (l *lease) skip(link string) {
+	if host, ok := l.end(link); ok {
+		l.run.refund(host)
+		l.frontier.Done(host)
+	}
+}
+
+// end the lease of link, it reports the host of link if it was dequeued.
+func (l *lease) end(link string) (string, bool) {
+	l.mu.Lock()
+	leased := l.leased[link] > 0
+	if leased {
+		decr(l.leased, link)
+	}
+	l.mu.Unlock()
+
+	if !leased {
+		return "", false
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+
+	return u.Hostname(), true
+}
+
+// enter the wbot limiter, link is about to be fetched.
+func (l *lease) enter(link string) {
+	host := link
+	if u, err := url.Parse(link); err == nil {
+		host = u.Hostname()
+	}
+
+	l.mu.Lock()
+	known := l.known[host]
+	l.mu.Unlock()
+
+	if known {
+		l.limiter.RLock()
+	} else {
+		l.limiter.Lock()
+	}
+
+	l.mu.Lock()
+	if known {
+		l.shared[link]++
+	} else {
+		l.alone[link]++
+	}
+	l.mu.Unlock()
+}
+
+// leave the wbot limiter, the first request of a crawl never entered it.
+func (l *lease) leave(link string, host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case l.alone[link] > 0:
+		decr(l.alone, link)
+		l.known[host] = true
+		l.limiter.Unlock()
+	case l.shared[link] > 0:
+		decr(l.shared, link)
+		l.limiter.RUnlock()
+	}
+}
+
+// decr
+func decr(m map[string]int, key string) {
+	if m[key] <= 1 {
+		delete(m, key)
+		return
+	}
+	m[key]--
+}
+
+// leasedQueue: the frontier, recording the lease of each dequeued request.
+type leasedQueue struct {
+	*frontier.Frontier
+	lease *lease
+}
+
+// Dequeue
+func (q *leasedQueue) Dequeue() (wbot.Request, error) {
+	for {
+		req, err := q.Frontier.Dequeue()
+		if err != nil || q.lease.take(req) {
+			return req, err
+		}
+	}
+}
+
+// leasedFetcher
+type leasedFetcher struct {
+	wbot.Fetcher
+	lease *lease
+}
+
+// Fetch
+func (f *leasedFetcher) Fetch(req wbot.Request) (wbot.Response, error) {
+	link := req.URL.String()
+
+	f.lease.leave(link, req.URL.Hostname())
+	defer f.lease.release(link)
+
+	return f.Fetcher.Fetch(req)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"github.com/twiny/domaincheck"
+)
+
+// seed modes of a result file
+const (
+	SeedPages     = "pages"     // pages domains were found on
+	SeedHomepages = "homepages" // homepages of registered domains
+)
+
+// ReadSeeds reads one url per line, blank lines and lines
+// starting with # are skipped.
+func ReadSeeds(r io.Reader) ([]string, error) {
+	var seeds []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+
+	return seeds, scanner.Err()
+}
+
+// ResultSeeds returns the seeds of a previous result file, either the
+// pages domains were found on or the homepages of registered domains.
+// files written without the url column fall back to homepages.
+func ResultSeeds(fp, mode string) ([]string, error) {
+	header, rows, err := readResults(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	col := columnIndex(header)
+
+	var (
+		seeds []string
+		seen  = map[string]bool{}
+	)
+
+	add := func(link string) {
+		if link != "" && !seen[link] {
+			seen[link] = true
+			seeds = append(seeds, link)
+		}
+	}
+
+	if _, found := col["url"]; !found && (mode == SeedPages || mode == "") {
+		log.Printf("%s: no url column, seeding from the homepages of registered domains", fp)
+		mode = SeedHomepages
+	}
+
+	switch mode {
+	case SeedPages, "":
+		for _, row := range rows {
+			add(field(row, col, "url"))
+		}
+	case SeedHomepages:
+		if _, found := col["status"]; !found {
+			return nil, fmt.Errorf("%s: no status column", fp)
+		}
+		for _, row := range rows {
+			// parked and for sale domains are registered
+			switch field(row, col, "status") {
+			case string(domaincheck.Registered), spider.SiteParked, spider.SiteForSale:
+			default:
+				continue
+			}
+			if name, tld, ok := rowDomain(row, col); ok {
+				add("https://" + name + "." + tld)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown seed mode %q", mode)
+	}
+
+	return seeds, nil
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"context"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/classify"
+)
+
+// site classifies the homepage of a registered domain.
+func (s *Spider) site(root string) classify.Result {
+	res, err := s.classify.Classify(context.Background(), root)
+	if err != nil {
+		s.log.Info("homepage unreachable", map[string]string{
+			"domain": root,
+			"error":  err.Error(),
+		})
+		return res
+	}
+
+	s.log.Info("homepage", map[string]string{
+		"domain": root,
+		"class":  res.Class,
+		"detail": res.Detail,
+	})
+
+	return res
+}
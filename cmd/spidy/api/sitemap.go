@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/sitemap"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"github.com/twiny/wbot"
+	"golang.org/x/net/publicsuffix"
+)
+
+// seeds returns links followed by the pages listed in sitemaps and,
+// if discovery is on, in the sitemaps of the seed hosts.
+func (s *Spider) seeds(links, sitemaps []string) []string {
+	var (
+		seeds []string
+		seen  = map[string]bool{}
+	)
+
+	add := func(link string) {
+		if !seen[link] {
+			seen[link] = true
+			seeds = append(seeds, link)
+		}
+	}
+
+	for _, link := range links {
+		add(link)
+	}
+
+	if s.setting.Sitemap.Discover {
+		sitemaps = append(sitemaps, s.discoverSitemaps(links)...)
+	}
+
+	if len(sitemaps) == 0 {
+		return seeds
+	}
+
+	var since time.Time
+	if s.setting.Sitemap.Since > 0 {
+		since = time.Now().Add(-s.setting.Sitemap.Since)
+	}
+
+	// robots.txt, headers and credentials apply to sitemaps as to pages
+	reader := sitemap.NewReader(s.fetch, userAgent(s.setting), s.setting.Sitemap.MaxURLs)
+
+	read := map[string]bool{}
+	for _, link := range sitemaps {
+		if read[link] {
+			continue
+		}
+		read[link] = true
+
+		pages, err := reader.Read(context.Background(), link, since)
+		if err != nil {
+			s.log.Error(err.Error(), map[string]string{
+				"sitemap": link,
+				"action":  "sitemap",
+			})
+		}
+
+		for _, page := range pages {
+			add(page.Loc)
+		}
+
+		s.log.Info("sitemap read", map[string]string{
+			"sitemap": link,
+			"pages":   strconv.Itoa(len(pages)),
+		})
+	}
+
+	return seeds
+}
+
+// discoverSitemaps returns the sitemaps listed in the robots.txt of each
+// seed host, or its /sitemap.xml if none.
+func (s *Spider) discoverSitemaps(links []string) []string {
+	var (
+		sitemaps []string
+		hosts    = map[string]bool{}
+	)
+
+	for _, link := range links {
+		u, err := url.Parse(link)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		base := u.Scheme + "://" + u.Host
+		if hosts[base] {
+			continue
+		}
+		hosts[base] = true
+
+		found := s.fetch.Sitemaps(u, userAgent(s.setting))
+		if len(found) == 0 {
+			found = []string{base + "/sitemap.xml"}
+		}
+
+		sitemaps = append(sitemaps, found...)
+	}
+
+	return sitemaps
+}
+
+// seedRequest: a depth 0 request for link.
+func (s *Spider) seedRequest(link string) (wbot.Request, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return wbot.Request{}, err
+	}
+
+	base, err := publicsuffix.EffectiveTLDPlusOne(u.Hostname())
+	if err != nil {
+		return wbot.Request{}, err
+	}
+
+	return wbot.Request{
+		BaseDomain: base,
+		URL:        u,
+		Depth:      0,
+		Param: wbot.Param{
+			Referer:     link,
+			MaxBodySize: s.setting.Crawler.MaxBodySize,
+		},
+	}, nil
+}
+
+// userAgent used outside the crawler.
+func userAgent(setting *spider.Setting) string {
+	if len(setting.Crawler.UserAgents) > 0 {
+		return setting.Crawler.UserAgents[0]
+	}
+	return ""
+}
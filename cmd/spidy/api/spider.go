@@ -3,24 +3,37 @@ package api
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	//
+	"github.com/twiny/spidy/v2/internal/pkg/canonical"
+	"github.com/twiny/spidy/v2/internal/pkg/guard"
+	"github.com/twiny/spidy/v2/internal/pkg/priority"
+	"github.com/twiny/spidy/v2/internal/pkg/proxy"
+	"github.com/twiny/spidy/v2/internal/pkg/score"
+	"github.com/twiny/spidy/v2/internal/pkg/signature"
 	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
-	"github.com/twiny/spidy/v2/internal/service/cache"
+	"github.com/twiny/spidy/v2/internal/service/classify"
+	"github.com/twiny/spidy/v2/internal/service/fetcher"
+	"github.com/twiny/spidy/v2/internal/service/frontier"
+	"github.com/twiny/spidy/v2/internal/service/probe"
 	"github.com/twiny/spidy/v2/internal/service/writer"
 
 	//
 	"github.com/twiny/domaincheck"
 	"github.com/twiny/flog"
 	"github.com/twiny/wbot"
+	"github.com/twiny/whois/v2"
 )
 
 //go:embed version
@@ -28,14 +41,31 @@ var Version string
 
 // Spider
 type Spider struct {
-	wg      *sync.WaitGroup
-	setting *spider.Setting
-	bot     *wbot.WBot
-	pages   chan *spider.Page
-	check   *domaincheck.Checker
-	store   spider.Storage
-	write   spider.Writer
-	log     *flog.Logger
+	wg       *sync.WaitGroup
+	setting  *spider.Setting
+	bot      *wbot.WBot
+	frontier *frontier.Frontier
+	visited  *visitedStore
+	run      *run
+	yields   *yields
+	traps    *traps
+	probe    *probe.Prober
+	classify *classify.Classifier
+	extract  map[int]bool
+	proxies  *proxy.Pool
+	guard    *guard.Guard
+	fetch    *fetcher.Fetcher
+	pages    chan *spider.Page
+	check    *domaincheck.Checker
+	whois    *whois.Client
+	score    *score.Scorer
+	store    spider.Storage
+	watch    spider.Watchlist
+	write    spider.Writer
+	log      *flog.Logger
+	once     *sync.Once
+	done     chan struct{}
+	loops    *sync.WaitGroup
 }
 
 // NewSpider
@@ -47,21 +77,60 @@ func NewSpider(fp string) (*Spider, error) {
 	opts := []wbot.Option{
 		wbot.SetParallel(setting.Parralle),
 		wbot.SetMaxDepth(setting.Crawler.MaxDepth),
-		wbot.SetRateLimit(setting.Crawler.Limit.Rate, setting.Crawler.Limit.Interval),
 		wbot.SetMaxBodySize(setting.Crawler.MaxBodySize),
 		wbot.SetUserAgents(setting.Crawler.UserAgents),
-		wbot.SetProxies(setting.Crawler.Proxies),
 	}
 
-	bot := wbot.NewWBot(opts...)
-
 	check, err := domaincheck.NewChecker()
 	if err != nil {
 		return nil, err
 	}
 
+	client, err := whois.NewClient(whois.Localhost)
+	if err != nil {
+		return nil, err
+	}
+
+	// scorer
+	scorer, err := score.NewScorer(setting.Score.Words, setting.Score.TLDs, score.ParseWeights(setting.Score.Weights))
+	if err != nil {
+		return nil, err
+	}
+
+	// parking and domain sale signatures
+	signs, err := signature.Load(setting.Classify.Signatures...)
+	if err != nil {
+		return nil, err
+	}
+
+	// proxy pool
+	list, err := proxies(setting)
+	if err != nil {
+		return nil, err
+	}
+	pool := proxy.New(proxy.Config{
+		Strategy:  setting.Proxy.Strategy,
+		MaxErrors: setting.Proxy.MaxErrors,
+		Evict:     setting.Proxy.Evict,
+	}, list)
+
+	// connections to reserved ips are rejected, proxies are allowed
+	var protect *guard.Guard
+	if setting.Guard.Enabled {
+		protect, err = guard.New(guard.Config{Allow: setting.Guard.Allow})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// headers, cookies and credentials
+	pageRequest, err := request(setting.Request)
+	if err != nil {
+		return nil, err
+	}
+
 	// store
-	store, err := cache.NewCache(setting.Store.TTL, setting.Store.Path)
+	store, err := OpenCache(setting)
 	if err != nil {
 		return nil, err
 	}
@@ -72,116 +141,501 @@ func NewSpider(fp string) (*Spider, error) {
 		return nil, err
 	}
 
-	write, err := writer.NewCSVWriter(setting.Result.Path)
+	// per host scheduler shared by all seeds
+	rules := make([]frontier.Rule, 0, len(setting.Politeness.Hosts))
+	for _, h := range setting.Politeness.Hosts {
+		rules = append(rules, frontier.Rule{
+			Pattern: h.Pattern,
+			Policy: frontier.Policy{
+				Delay:    h.Delay,
+				MaxConns: h.MaxConns,
+			},
+		})
+	}
+
+	var queue *frontier.Frontier
+	budget := newRun(setting.Budget, func() { queue.Close() })
+
+	// dead link mode: only domains of broken links are checked
+	var (
+		find  = spider.FindDomains
+		links *probe.Prober
+	)
+	if setting.DeadLinks.Enabled {
+		find = spider.FindLinks
+		links = probe.NewProber(probe.Config{
+			Timeout:   setting.DeadLinks.Timeout,
+			UserAgent: userAgent(setting),
+			TTL:       setting.DeadLinks.TTL,
+			Signs:     signs,
+			Guard:     protect,
+		})
+	}
+
+	var sites *classify.Classifier
+	if setting.Classify.Enabled {
+		sites = classify.NewClassifier(classify.Config{
+			Timeout:   setting.Classify.Timeout,
+			UserAgent: userAgent(setting),
+			Signs:     signs,
+			Guard:     protect,
+		})
+	}
+
+	// statuses of pages whose domains are extracted
+	extract := map[int]bool{}
+	for _, status := range setting.Crawler.Extract {
+		extract[status] = true
+	}
+
+	// links are ranked by what their parent page yielded
+	var (
+		yields = newYields(store, setting.TLDs, extract, find)
+		rank   frontier.Scorer
+		traps  *traps
+		admit  func(wbot.Request) bool
+	)
+	if setting.Priority.Enabled {
+		rank = &yieldScorer{
+			score:  priority.NewScorer(priority.ParseWeights(setting.Priority.Weights), setting.Priority.Keywords),
+			yields: yields,
+			run:    budget,
+		}
+	}
+
+	// crawl traps: url patterns that stopped yielding are skipped
+	if setting.Trap.Enabled {
+		traps = newTraps(setting.Trap, log)
+		admit = traps.admit
+	}
+
+	queue = frontier.New(frontier.Config{
+		Workers:  setting.Parralle,
+		MaxDepth: setting.Crawler.MaxDepth,
+		Policy: frontier.Policy{
+			Delay:    setting.Politeness.Delay,
+			MaxConns: setting.Politeness.MaxConns,
+		},
+		Rules:    rules,
+		Rate:     setting.Politeness.Rate,
+		Interval: setting.Politeness.Interval,
+		Allow:    budget.allow,
+		Admit:    admit,
+		Scorer:   rank,
+	})
+	leases := newLease(queue, budget)
+
+	// visited urls
+	visited := &visitedStore{
+		canon:  canonical.New(setting.Crawler.StripParams),
+		visits: store,
+		lease:  leases,
+		log:    log,
+	}
+	opts = append(opts, wbot.SetStore(visited))
+
+	// robots.txt aware fetcher
+	conf := fetcher.Config{
+		Robots:       setting.Robots.Enabled,
+		RobotsTTL:    setting.Robots.TTL,
+		RobotsIgnore: setting.Robots.Ignore,
+		MaxDelay:     setting.Robots.MaxDelay,
+		Retry: fetcher.Retry{
+			Attempts:   setting.Retry.Attempts,
+			Statuses:   setting.Retry.Statuses,
+			Backoff:    setting.Retry.Backoff,
+			MaxWait:    setting.Retry.MaxWait,
+			MaxBackoff: setting.Retry.MaxBackoff,
+		},
+		Backoff: queue.Backoff,
+		Request: pageRequest,
+		Guard:   protect,
+	}
+	if setting.Store.HTTPCache {
+		conf.Validators = store
+	}
+	fetch := fetcher.NewFetcher(conf)
+
+	opts = append(opts,
+		wbot.SetQueue(&leasedQueue{Frontier: queue, lease: leases}),
+		wbot.SetFetcher(&leasedFetcher{
+			Fetcher: &yieldFetcher{
+				Fetcher: &proxyFetcher{Fetcher: fetch, pool: pool},
+				yields:  yields,
+				traps:   traps,
+			},
+			lease: leases,
+		}),
+		wbot.SetLogger(&botLog{log: log}),
+	)
+
+	bot := wbot.NewWBot(opts...)
+
+	csv, err := writer.NewCSVWriter(writer.CSVConfig{
+		Dir:      setting.Result.Path,
+		Filename: setting.Result.Filename,
+		Columns:  setting.Result.Columns,
+		Rotate:   setting.Result.Rotate,
+		MaxSize:  setting.Result.MaxSize,
+		Compress: setting.Result.Compress,
+	})
 	if err != nil {
 		return nil, err
 	}
+	write := writer.NewBufferedWriter(csv, setting.Result.FlushSize, setting.Result.FlushInterval)
 
 	return &Spider{
-		wg:      &sync.WaitGroup{},
-		setting: setting,
-		bot:     bot,
-		pages:   make(chan *spider.Page, setting.Parralle),
-		check:   check,
-		store:   store,
-		write:   write,
-		log:     log,
+		wg:       &sync.WaitGroup{},
+		setting:  setting,
+		bot:      bot,
+		frontier: queue,
+		visited:  visited,
+		run:      budget,
+		yields:   yields,
+		traps:    traps,
+		probe:    links,
+		classify: sites,
+		extract:  extract,
+		proxies:  pool,
+		guard:    protect,
+		fetch:    fetch,
+		pages:    make(chan *spider.Page, setting.Parralle),
+		check:    check,
+		whois:    client,
+		score:    scorer,
+		store:    store,
+		watch:    store,
+		write:    write,
+		log:      log,
+		once:     &sync.Once{},
+		done:     make(chan struct{}),
+		loops:    &sync.WaitGroup{},
 	}, nil
 }
 
-// Start
-func (s *Spider) Start(links []string) error {
-	// go crawl
-	s.wg.Add(len(links))
-	for _, link := range links {
-		go func(l string) {
-			defer s.wg.Done()
-			//
-			if err := s.bot.Crawl(l); err != nil {
-				s.log.Error(err.Error(), map[string]string{"url": l})
-			}
-		}(link)
+// Start crawls from links and from the pages listed in sitemaps.
+func (s *Spider) Start(links, sitemaps []string) error {
+	// monitor watched domains while crawling
+	if s.setting.Watch.Enabled {
+		s.loops.Add(1)
+		go s.watchLoop()
+	}
+
+	s.run.start()
+
+	// reload the proxies file
+	if s.setting.Proxy.File != "" && s.setting.Proxy.Reload > 0 {
+		s.loops.Add(1)
+		go s.proxyLoop()
 	}
 
+	seeds := s.seeds(links, sitemaps)
+	if len(seeds) == 0 {
+		return errors.New("no seed url")
+	}
+
+	// go crawl
+	failed := make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if !s.crawl(seeds) {
+			close(failed)
+		}
+	}()
+
 	// check domains
+	stream := s.bot.Stream()
 	s.wg.Add(s.setting.Parralle)
 	for i := 0; i < s.setting.Parralle; i++ {
 		go func() {
 			defer s.wg.Done()
 			// results
-			for res := range s.bot.Stream() {
-				// if response is ok
-				if res.Status != http.StatusOK {
-					s.log.Info("bad HTTP status", map[string]string{
-						"url":    res.URL.String(),
-						"status": strconv.Itoa(res.Status),
-					})
-					continue
+			for {
+				select {
+				case res, ok := <-stream:
+					if !ok {
+						return
+					}
+					s.handle(res)
+				case <-failed:
+					return
 				}
+			}
+		}()
+	}
 
-				// extract domains
-				domains := spider.FindDomains(res.Body)
-
-				// check availability
-				for _, domain := range domains {
-					root := fmt.Sprintf("%s.%s", domain.Name, domain.TLD)
-
-					// check if allowed extension
-					if len(s.setting.TLDs) > 0 {
-						if ok := s.setting.TLDs[domain.TLD]; !ok {
-							s.log.Info("unsupported domain", map[string]string{
-								"domain": root,
-								"url":    res.URL.String(),
-							})
-							continue
-						}
-					}
+	s.wg.Wait()
+	return nil
+}
 
-					// skip if already checked
-					if s.store.HasChecked(root) {
-						s.log.Info("already checked", map[string]string{
-							"domain": root,
-							"url":    res.URL.String(),
-						})
-						continue
-					}
+// crawl queues all seeds and starts the crawler from the first one that
+// can be fetched, it returns false if none could. seeds are admitted by
+// the frontier, budgets and traps included, and skipped if visited.
+func (s *Spider) crawl(seeds []string) bool {
+	for _, link := range seeds {
+		req, err := s.seedRequest(link)
+		if err != nil {
+			s.log.Error(err.Error(), map[string]string{"url": link})
+			continue
+		}
+		s.frontier.Enqueue(req)
+	}
 
-					//
-					ctx, cancel := context.WithTimeout(context.Background(), s.setting.Timeout)
-					defer cancel()
-
-					status, err := s.check.Check(ctx, root)
-					if err != nil {
-						s.log.Error(err.Error(), map[string]string{
-							"domain": root,
-							"url":    res.URL.String(),
-						})
-						continue
-					}
+	for s.frontier.Len() > 0 && s.frontier.Next() {
+		req, err := s.frontier.Dequeue()
+		if err != nil {
+			continue
+		}
+		// fetched as the first request, outside the frontier
+		s.frontier.Done(req.URL.Hostname())
 
-					// save domain
-					if err := s.write.Write(&spider.Domain{
-						URL:    res.URL.String(),
-						Name:   domain.Name,
-						TLD:    domain.TLD,
-						Status: status.String(),
-					}); err != nil {
-						s.log.Error(err.Error(), map[string]string{
-							"domain": root,
-							"url":    res.URL.String(),
-						})
-						continue
-					}
+		link := req.URL.String()
+		if !s.run.take(req.URL.Hostname()) {
+			continue
+		}
+		if s.visited.visit(link) {
+			s.run.refund(req.URL.Hostname())
+			continue
+		}
 
-					// terminal print
-					fmt.Printf("[Spidy] == domain: %s - status %s\n", root, status.String())
-				}
+		// blocks until the crawl is over
+		err = s.bot.Crawl(link)
+		if err == nil {
+			return true
+		}
+		s.log.Error(err.Error(), map[string]string{"url": link})
+	}
+
+	return false
+}
+
+// handle extracts and checks the domains of a crawled page.
+func (s *Spider) handle(res wbot.Response) {
+	s.run.page(strings.ToLower(res.URL.Hostname()), len(res.Body))
+
+	// unchanged since the last run
+	if res.Status == http.StatusNotModified {
+		s.log.Info("not modified", map[string]string{
+			"url": res.URL.String(),
+		})
+		return
+	}
+
+	// if the page has domains to extract
+	if !s.extract[res.Status] {
+		s.log.Info("bad HTTP status", map[string]string{
+			"url":    res.URL.String(),
+			"status": strconv.Itoa(res.Status),
+		})
+		return
+	}
+
+	// extract domains
+	domains := s.yields.domains(res)
+
+	// a domain could not be checked
+	var failed bool
+
+	// check availability
+	for _, domain := range domains {
+		root := fmt.Sprintf("%s.%s", domain.Name, domain.TLD)
+
+		// check if allowed extension
+		if len(s.setting.TLDs) > 0 {
+			if ok := s.setting.TLDs[domain.TLD]; !ok {
+				s.log.Info("unsupported domain", map[string]string{
+					"domain": root,
+					"url":    res.URL.String(),
+				})
+				continue
 			}
-		}()
+		}
+
+		// record sighting
+		now := time.Now()
+		sighting := domain.Referrers
+		sighting.Pages = 1
+		sighting.Hosts = []string{res.URL.Hostname()}
+		sighting.FirstSeen = now
+		sighting.LastSeen = now
+
+		rec, ok, err := s.store.Reserve(root, sighting)
+		if err != nil {
+			failed = true
+			s.log.Error(err.Error(), map[string]string{
+				"domain": root,
+				"url":    res.URL.String(),
+			})
+			continue
+		}
+
+		// skip if already checked or being checked
+		if !ok {
+			s.log.Info("already checked", map[string]string{
+				"domain": root,
+				"url":    res.URL.String(),
+			})
+
+			// the sighting is merged into the stored referrers,
+			// rank reads the merged stats from there
+			if !s.setting.Store.WriteHits || !rec.Checked() {
+				continue
+			}
+
+			if !s.deadLink(res, &domain, root) {
+				continue
+			}
+
+			if err := s.write.Write(&spider.Domain{
+				URL:        res.URL.String(),
+				Name:       domain.Name,
+				TLD:        domain.TLD,
+				Status:     rec.Status,
+				CheckedAt:  rec.CheckedAt,
+				Referrers:  rec.Referrers,
+				Score:      s.score.Score(domain.Name, domain.TLD, rec.Referrers.Pages),
+				Cached:     true,
+				Link:       domain.Link,
+				LinkError:  domain.LinkError,
+				Site:       rec.Site,
+				SiteDetail: rec.SiteDetail,
+			}); err != nil {
+				s.log.Error(err.Error(), map[string]string{
+					"domain": root,
+					"url":    res.URL.String(),
+				})
+			}
+			continue
+		}
+
+		// the domain is reserved, free it for other links if this one works
+		if !s.deadLink(res, &domain, root) {
+			if err := s.store.Release(root, nil); err != nil {
+				s.log.Error(err.Error(), map[string]string{
+					"domain": root,
+					"url":    res.URL.String(),
+				})
+			}
+			continue
+		}
+
+		//
+		ctx, cancel := context.WithTimeout(context.Background(), s.setting.Timeout)
+		status, err := s.check.Check(ctx, root)
+		cancel()
+
+		if err != nil {
+			failed = true
+			s.log.Error(err.Error(), map[string]string{
+				"domain": root,
+				"url":    res.URL.String(),
+			})
+
+			// free domain for a retry
+			if err := s.store.Release(root, err); err != nil {
+				s.log.Error(err.Error(), map[string]string{
+					"domain": root,
+					"url":    res.URL.String(),
+				})
+			}
+			continue
+		}
+
+		s.run.check(status == domaincheck.Available)
+
+		// parked and for sale domains
+		var site classify.Result
+		if s.classify != nil && status == domaincheck.Registered {
+			site = s.site(root)
+		}
+
+		checkedAt := time.Now()
+
+		// remember result
+		if err := s.store.Commit(&spider.Record{
+			Name:       root,
+			Status:     status.String(),
+			Backend:    spider.BackendWHOIS,
+			CheckedAt:  checkedAt,
+			Site:       site.Class,
+			SiteDetail: site.Detail,
+		}); err != nil {
+			s.log.Error(err.Error(), map[string]string{
+				"domain": root,
+				"url":    res.URL.String(),
+			})
+		}
+
+		found := &spider.Domain{
+			URL:        res.URL.String(),
+			Name:       domain.Name,
+			TLD:        domain.TLD,
+			Status:     status.String(),
+			CheckedAt:  checkedAt,
+			Referrers:  rec.Referrers,
+			Score:      s.score.Score(domain.Name, domain.TLD, rec.Referrers.Pages),
+			Link:       domain.Link,
+			LinkError:  domain.LinkError,
+			Site:       site.Class,
+			SiteDetail: site.Detail,
+		}
+
+		// watch registered domains until they drop
+		if s.setting.Watch.Enabled && status == domaincheck.Registered {
+			if err := s.watchDomain(found); err != nil {
+				s.log.Error(err.Error(), map[string]string{
+					"domain": root,
+					"action": "watchlist",
+				})
+			}
+		}
+
+		// save domain
+		if err := s.write.Write(found); err != nil {
+			s.log.Error(err.Error(), map[string]string{
+				"domain": root,
+				"url":    res.URL.String(),
+			})
+			continue
+		}
+
+		// terminal print
+		fmt.Printf("[Spidy] == domain: %s - status %s\n", root, found.Reported())
 	}
 
-	s.wg.Wait()
-	return nil
+	// validators are kept once every domain is checked, else the page
+	// is fetched in full again next time
+	if failed {
+		return
+	}
+	if err := s.fetch.Handled(res); err != nil {
+		s.log.Error(err.Error(), map[string]string{
+			"url":    res.URL.String(),
+			"action": "http cache",
+		})
+	}
+}
+
+// deadLink: in dead link mode, probes the link to domain and reports if
+// it is dead, the reason is kept on domain. always true otherwise.
+func (s *Spider) deadLink(res wbot.Response, domain *spider.Domain, root string) bool {
+	if s.probe == nil {
+		return true
+	}
+
+	link := s.probe.Probe(context.Background(), domain.Link)
+	if !link.Dead {
+		s.log.Info("live link", map[string]string{
+			"domain": root,
+			"link":   domain.Link,
+			"url":    res.URL.String(),
+		})
+		return false
+	}
+
+	domain.LinkError = link.Reason
+	return true
 }
 
 // Shutdown
@@ -192,6 +646,7 @@ func (s *Spider) Shutdown() error {
 
 	<-sigs
 	log.Println("shutting down ...")
+	s.run.halt(StopInterrupted)
 
 	// 2nd ctrl+c kills program
 	go func() {
@@ -202,11 +657,38 @@ func (s *Spider) Shutdown() error {
 		os.Exit(0)
 	}()
 
-	s.bot.Close()
-	s.log.Close()
-	if err := s.store.Close(); err != nil {
-		return err
+	// let the crawl drain, pages being handled are still written
+	s.wg.Wait()
+
+	if err := s.Close(); err != nil {
+		log.Println(err)
 	}
 	os.Exit(0)
 	return nil
 }
+
+// Close flushes pending results and releases all resources.
+// it is safe to call Close more than once.
+func (s *Spider) Close() error {
+	var err error
+	s.once.Do(func() {
+		// stop background loops
+		close(s.done)
+		s.loops.Wait()
+
+		s.bot.Close()
+
+		if werr := s.write.Close(); werr != nil {
+			err = werr
+		}
+
+		s.report()
+
+		if serr := s.store.Close(); serr != nil && err == nil {
+			err = serr
+		}
+
+		s.log.Close()
+	})
+	return err
+}
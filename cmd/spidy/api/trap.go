@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+	"github.com/twiny/spidy/v2/internal/pkg/trap"
+
+	//
+	"github.com/twiny/flog"
+	"github.com/twiny/wbot"
+)
+
+// traps keeps the crawler out of calendars, faceted search and session urls.
+type traps struct {
+	detect *trap.Detector
+	log    *flog.Logger
+}
+
+// newTraps
+func newTraps(conf spider.TrapSetting, log *flog.Logger) *traps {
+	return &traps{
+		detect: trap.NewDetector(trap.Config{
+			MaxLength:  conf.MaxLength,
+			MaxRepeats: conf.MaxRepeats,
+			MaxPages:   conf.MaxPages,
+			Patience:   conf.Patience,
+			Distance:   conf.Distance,
+		}),
+		log: log,
+	}
+}
+
+// admit a queued request, urls of closed patterns are dropped.
+func (t *traps) admit(req wbot.Request) bool {
+	_, ok := t.detect.Allow(req.URL)
+	return ok
+}
+
+// page records a fetched page and the new domains it yielded.
+func (t *traps) page(u *url.URL, body []byte, fresh int) {
+	if t.detect.Page(u, body, fresh) {
+		t.log.Info("crawl trap", map[string]string{
+			"pattern": trap.Pattern(u),
+			"url":     u.String(),
+		})
+	}
+}
+
+// report logs and prints the trap counters.
+func (t *traps) report() {
+	st := t.detect.Stats()
+	skipped := st.Length + st.Repeats + st.Pattern
+	if skipped == 0 && st.Duplicates == 0 {
+		return
+	}
+
+	t.log.Info("crawl traps", map[string]string{
+		"length":     strconv.FormatUint(st.Length, 10),
+		"repeats":    strconv.FormatUint(st.Repeats, 10),
+		"pattern":    strconv.FormatUint(st.Pattern, 10),
+		"duplicates": strconv.FormatUint(st.Duplicates, 10),
+		"closed":     strconv.FormatUint(st.Closed, 10),
+	})
+
+	fmt.Printf("[Spidy] == traps: %d urls skipped - too long %d, repeated segments %d, %d from %d closed patterns - %d near duplicate pages\n",
+		skipped, st.Length, st.Repeats, st.Pattern, st.Closed, st.Duplicates)
+}
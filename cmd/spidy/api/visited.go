@@ -0,0 +1,53 @@
+package api
+
+import (
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/canonical"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"github.com/twiny/flog"
+)
+
+// visitedStore: a wbot.Store keeping canonical urls in the domain store,
+// so a page is fetched once per visit ttl across runs.
+type visitedStore struct {
+	canon  *canonical.Canonicalizer
+	visits spider.Visits
+	lease  *lease
+	log    *flog.Logger
+}
+
+// Visited
+func (v *visitedStore) Visited(link string) bool {
+	seen := v.visit(link)
+
+	if seen {
+		v.lease.skip(link)
+	} else {
+		v.lease.enter(link)
+	}
+
+	return seen
+}
+
+// visit marks link as visited, it reports whether it already was.
+func (v *visitedStore) visit(link string) bool {
+	key, err := v.canon.String(link)
+	if err != nil {
+		key = link
+	}
+
+	seen, err := v.visits.Visit(key)
+	if err != nil {
+		v.log.Error(err.Error(), map[string]string{"url": link})
+		return false
+	}
+
+	return seen
+}
+
+// Close: the domain store is closed by the spider.
+func (v *visitedStore) Close() error {
+	return nil
+}
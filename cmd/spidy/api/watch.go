@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/expiry"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"github.com/twiny/domaincheck"
+)
+
+// Watch re-checks watched domains until Close is called.
+func (s *Spider) Watch() {
+	s.loops.Add(1)
+	s.watchLoop()
+}
+
+// watchLoop
+func (s *Spider) watchLoop() {
+	defer s.loops.Done()
+
+	ticker := time.NewTicker(s.setting.Watch.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.recheck(time.Now())
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchDomain adds a registered domain to the watchlist.
+func (s *Spider) watchDomain(d *spider.Domain) error {
+	w := &spider.Watch{
+		Name:   d.Name,
+		TLD:    d.TLD,
+		URL:    d.URL,
+		Status: d.Status,
+	}
+
+	if err := s.inspect(w, time.Now()); err != nil {
+		return err
+	}
+
+	d.Expiry = w.Expiry
+
+	return s.watch.Watch(w)
+}
+
+// recheck watched domains due at now.
+func (s *Spider) recheck(now time.Time) {
+	due, err := s.watch.Due(now)
+	if err != nil {
+		s.log.Error(err.Error(), map[string]string{"action": "watchlist"})
+		return
+	}
+
+	for _, w := range due {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		root := w.Name + "." + w.TLD
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.setting.Timeout)
+		status, err := s.check.Check(ctx, root)
+		cancel()
+
+		if err != nil {
+			s.log.Error(err.Error(), map[string]string{
+				"domain": root,
+				"action": "watchlist",
+			})
+			w.NextCheck = now.Add(s.setting.Watch.DropEvery)
+			if err := s.watch.Watch(w); err != nil {
+				s.log.Error(err.Error(), map[string]string{"domain": root})
+			}
+			continue
+		}
+
+		if err := s.store.Commit(&spider.Record{
+			Name:      root,
+			Status:    status.String(),
+			Backend:   spider.BackendWHOIS,
+			CheckedAt: now,
+		}); err != nil {
+			s.log.Error(err.Error(), map[string]string{"domain": root})
+		}
+
+		// dropped
+		if status != domaincheck.Registered {
+			if err := s.write.Write(&spider.Domain{
+				URL:        w.URL,
+				Name:       w.Name,
+				TLD:        w.TLD,
+				Status:     status.String(),
+				CheckedAt:  now,
+				Expiry:     w.Expiry,
+				PrevStatus: w.Status,
+				Score:      s.score.Score(w.Name, w.TLD, 0),
+			}); err != nil {
+				s.log.Error(err.Error(), map[string]string{"domain": root})
+			}
+
+			if err := s.watch.Unwatch(root); err != nil {
+				s.log.Error(err.Error(), map[string]string{"domain": root})
+			}
+
+			fmt.Printf("[Spidy] == domain: %s - status changed %s -> %s\n", root, w.Status, status.String())
+			continue
+		}
+
+		phase := w.Phase
+		if err := s.inspect(w, now); err != nil {
+			s.log.Error(err.Error(), map[string]string{
+				"domain": root,
+				"action": "watchlist",
+			})
+			w.NextCheck = now.Add(s.setting.Watch.DropEvery)
+		}
+
+		if w.Phase != phase {
+			s.log.Info("phase changed", map[string]string{
+				"domain": root,
+				"from":   phase,
+				"to":     w.Phase,
+			})
+		}
+
+		if err := s.watch.Watch(w); err != nil {
+			s.log.Error(err.Error(), map[string]string{"domain": root})
+		}
+	}
+}
+
+// inspect looks up the WHOIS record of w to update
+// its expiry date, phase and next check.
+func (s *Spider) inspect(w *spider.Watch, now time.Time) error {
+	root := w.Name + "." + w.TLD
+
+	server, err := s.whois.WHOISHost(root)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.setting.Timeout)
+	defer cancel()
+
+	raw, err := s.whois.Lookup(ctx, root, server)
+	if err != nil {
+		return err
+	}
+
+	if exp, ok := expiry.Parse(raw); ok {
+		w.Expiry = exp
+	}
+
+	w.Phase = expiry.Phase(raw, w.Expiry, s.setting.Watch.Window, now)
+	w.CheckedAt = now
+	w.NextCheck = s.nextCheck(w, now)
+
+	return nil
+}
+
+// nextCheck: the closer a domain is to drop the more often it is checked.
+func (s *Spider) nextCheck(w *spider.Watch, now time.Time) time.Time {
+	conf := s.setting.Watch
+
+	switch {
+	case expiry.Dropping(w.Phase):
+		return now.Add(conf.DropEvery)
+	case w.Phase == expiry.Expiring:
+		return now.Add(conf.NearEvery)
+	case w.Expiry.IsZero():
+		// unknown expiry, look again later
+		return now.Add(conf.Window)
+	default:
+		return w.Expiry.Add(-conf.Window)
+	}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	//
 
 	"github.com/twiny/spidy/v2/cmd/spidy/api"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+	"github.com/twiny/spidy/v2/internal/service/cache"
 
 	//
 	"github.com/urfave/cli/v2"
@@ -27,20 +33,104 @@ func main() {
 				Required: true,
 			},
 			&cli.StringSliceFlag{
-				Name:     "urls",
-				Aliases:  []string{"u"},
-				Usage:    "`urls` of page to scrape",
-				Required: true,
+				Name:    "urls",
+				Aliases: []string{"u"},
+				Usage:   "`urls` of page to scrape",
+			},
+			&cli.StringSliceFlag{
+				Name:  "sitemap",
+				Usage: "`urls` of sitemaps listing pages to scrape",
+			},
+			&cli.StringFlag{
+				Name:  "urls-file",
+				Usage: "read urls from `path`, one per line, - for stdin",
+			},
+			&cli.StringFlag{
+				Name:  "seeds-from-results",
+				Usage: "seed from a previous result file at `path`",
+			},
+			&cli.StringFlag{
+				Name:  "seed-mode",
+				Usage: "seeds taken from results: pages (where domains were found) or homepages (of registered domains)",
+				Value: api.SeedPages,
 			},
 		},
 		Action: func(c *cli.Context) error {
+			links, err := seedLinks(c)
+			if err != nil {
+				return err
+			}
+
+			sitemaps := c.StringSlice("sitemap")
+			if len(links) == 0 && len(sitemaps) == 0 {
+				return errors.New("at least one url or sitemap is required")
+			}
+
 			s, err := api.NewSpider(c.String("config"))
 			if err != nil {
 				return err
 			}
 			go s.Shutdown()
 
-			return s.Start(c.StringSlice("urls"))
+			if err := s.Start(links, sitemaps); err != nil {
+				s.Close()
+				return err
+			}
+
+			return s.Close()
+		},
+		Commands: []*cli.Command{
+			cacheCommand,
+			{
+				Name:  "watch",
+				Usage: "re-check watched domains until they drop",
+				Action: func(c *cli.Context) error {
+					s, err := api.NewSpider(c.String("config"))
+					if err != nil {
+						return err
+					}
+					go s.Shutdown()
+
+					s.Watch()
+
+					return s.Close()
+				},
+			},
+			{
+				Name:      "rank",
+				Usage:     "sort a result file by domain score",
+				ArgsUsage: "<result file>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "write ranked results to `path` instead of stdout",
+					},
+					&cli.StringFlag{
+						Name:  "status",
+						Usage: "only keep domains with `status`",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return errors.New("a result file is required")
+					}
+
+					var w io.Writer = os.Stdout
+					if fp := c.String("output"); fp != "" {
+						f, err := os.Create(fp)
+						if err != nil {
+							return err
+						}
+						defer f.Close()
+						w = f
+					}
+
+					setting := spider.ParseSetting(c.String("config"))
+
+					return api.Rank(setting, c.Args().First(), c.String("status"), w)
+				},
+			},
 		},
 	}
 
@@ -49,3 +139,182 @@ func main() {
 		return
 	}
 }
+
+// seedLinks collects seed urls from the flags, a file or stdin and a result file.
+func seedLinks(c *cli.Context) ([]string, error) {
+	links := c.StringSlice("urls")
+
+	if fp := c.String("urls-file"); fp != "" {
+		var r io.Reader = os.Stdin
+		if fp != "-" {
+			f, err := os.Open(fp)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		seeds, err := api.ReadSeeds(r)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, seeds...)
+	}
+
+	if fp := c.String("seeds-from-results"); fp != "" {
+		seeds, err := api.ResultSeeds(fp, c.String("seed-mode"))
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, seeds...)
+	}
+
+	return links, nil
+}
+
+// filter flags
+var filterFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "pattern",
+		Usage: "only domains matching `glob`, e.g. *.io",
+	},
+	&cli.StringFlag{
+		Name:  "status",
+		Usage: "only domains with `status`",
+	},
+}
+
+// cacheCommand
+var cacheCommand = &cli.Command{
+	Name:  "cache",
+	Usage: "inspect and manage the domain store",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "list",
+			Usage: "list stored domains with their status and age",
+			Flags: filterFlags,
+			Action: withCache(func(c *cli.Context, store *cache.Cache) error {
+				return api.CacheList(store, cacheFilter(c), os.Stdout)
+			}),
+		},
+		{
+			Name:      "get",
+			Usage:     "show the stored record of a domain",
+			ArgsUsage: "<domain>",
+			Action: withCache(func(c *cli.Context, store *cache.Cache) error {
+				if c.NArg() != 1 {
+					return errors.New("a domain is required")
+				}
+				return api.CacheGet(store, c.Args().First(), os.Stdout)
+			}),
+		},
+		{
+			Name:  "export",
+			Usage: "export stored domains as jsonl or csv",
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "export `format`: jsonl or csv",
+					Value: api.FormatJSONL,
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write to `path` instead of stdout",
+				},
+			}, filterFlags...),
+			Action: withCache(func(c *cli.Context, store *cache.Cache) error {
+				var w io.Writer = os.Stdout
+				if fp := c.String("output"); fp != "" {
+					f, err := os.Create(fp)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					w = f
+				}
+				return api.CacheExport(store, cacheFilter(c), c.String("format"), w)
+			}),
+		},
+		{
+			Name:      "import",
+			Usage:     "import domains from a previous export",
+			ArgsUsage: "<file>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "import `format`: jsonl or csv, guessed from the file extension by default",
+				},
+			},
+			Action: withCache(func(c *cli.Context, store *cache.Cache) error {
+				if c.NArg() != 1 {
+					return errors.New("a file is required")
+				}
+
+				fp := c.Args().First()
+				format := c.String("format")
+				if format == "" {
+					format = api.FormatJSONL
+					if strings.HasSuffix(fp, ".csv") {
+						format = api.FormatCSV
+					}
+				}
+
+				f, err := os.Open(fp)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				n, err := api.CacheImport(store, format, f)
+				fmt.Printf("imported %d domains\n", n)
+				return err
+			}),
+		},
+		{
+			Name:  "purge",
+			Usage: "delete stored domains by pattern or status",
+			Flags: filterFlags,
+			Action: withCache(func(c *cli.Context, store *cache.Cache) error {
+				n, err := api.CachePurge(store, cacheFilter(c))
+				fmt.Printf("purged %d domains\n", n)
+				return err
+			}),
+		},
+		{
+			Name:  "compact",
+			Usage: "drop expired entries",
+			Action: withCache(func(c *cli.Context, store *cache.Cache) error {
+				n, err := store.Compact()
+				fmt.Printf("dropped %d expired entries\n", n)
+				return err
+			}),
+		},
+	},
+}
+
+// withCache opens the configured store for the duration of fn.
+func withCache(fn func(c *cli.Context, store *cache.Cache) error) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		store, err := api.OpenCache(spider.ParseSetting(c.String("config")))
+		if err != nil {
+			return err
+		}
+
+		if err := fn(c, store); err != nil {
+			store.Close()
+			return err
+		}
+
+		return store.Close()
+	}
+}
+
+// cacheFilter
+func cacheFilter(c *cli.Context) api.CacheFilter {
+	return api.CacheFilter{
+		Pattern: c.String("pattern"),
+		Status:  c.String("status"),
+	}
+}
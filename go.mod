@@ -4,11 +4,14 @@ go 1.18
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.0
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/twiny/carbon v1.0.1
 	github.com/twiny/domaincheck v0.1.0
 	github.com/twiny/flog v1.0.3
 	github.com/twiny/wbot v0.1.5
+	github.com/twiny/whois/v2 v2.0.1
 	github.com/urfave/cli/v2 v2.10.3
+	go.etcd.io/bbolt v1.3.7
 	golang.org/x/net v0.0.0-20220513224357-95641704303c
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -36,9 +39,8 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/twiny/ratelimit v0.0.0-20220509163414-256d3376b0ac // indirect
-	github.com/twiny/whois/v2 v2.0.1 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
 	go.opencensus.io v0.22.5 // indirect
-	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
+	golang.org/x/sys v0.4.0 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
@@ -0,0 +1,262 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+	"sync"
+)
+
+// scaling: each new filter is `growth` times larger
+// with a false positive rate `tightening` times smaller.
+const (
+	growth     = 2
+	tightening = 0.5
+)
+
+var magic = [4]byte{'S', 'P', 'B', 'F'}
+
+// Errs
+var (
+	ErrInvalid = errors.New("invalid bloom filter data")
+)
+
+// Filter: a scalable bloom filter, a test may return a false positive
+// but never a false negative. a new filter is added when the last one is full.
+type Filter struct {
+	mu      *sync.RWMutex
+	fp      float64
+	filters []*filter
+}
+
+// filter
+type filter struct {
+	bits     []uint64
+	m        uint64 // bits
+	k        uint64 // hashes
+	n        uint64 // items added
+	capacity uint64
+}
+
+// New: capacity is the expected number of items of the first filter and
+// fp the target false positive rate of the whole filter.
+func New(capacity uint64, fp float64) *Filter {
+	if capacity == 0 {
+		capacity = 1024
+	}
+	if fp <= 0 || fp >= 1 {
+		fp = 0.01
+	}
+
+	return &Filter{
+		mu:      &sync.RWMutex{},
+		fp:      fp,
+		filters: []*filter{newFilter(capacity, fp*(1-tightening))},
+	}
+}
+
+// newFilter
+func newFilter(capacity uint64, fp float64) *filter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(capacity)*math.Ln2)))
+
+	return &filter{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+// Add
+func (f *Filter) Add(key string) {
+	h1, h2 := hash(key)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, b := range f.filters {
+		if b.test(h1, h2) {
+			return
+		}
+	}
+
+	last := f.filters[len(f.filters)-1]
+	if last.n >= last.capacity {
+		fp := f.fp * (1 - tightening) * math.Pow(tightening, float64(len(f.filters)))
+		last = newFilter(last.capacity*growth, fp)
+		f.filters = append(f.filters, last)
+	}
+
+	last.add(h1, h2)
+}
+
+// Test reports whether key may have been added.
+func (f *Filter) Test(key string) bool {
+	h1, h2 := hash(key)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, b := range f.filters {
+		if b.test(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count of items added.
+func (f *Filter) Count() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var n uint64
+	for _, b := range f.filters {
+		n += b.n
+	}
+	return n
+}
+
+// WriteTo
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	cw := &countWriter{w: w}
+
+	header := []interface{}{magic, math.Float64bits(f.fp), uint64(len(f.filters))}
+	for _, v := range header {
+		if err := binary.Write(cw, binary.LittleEndian, v); err != nil {
+			return cw.n, err
+		}
+	}
+
+	for _, b := range f.filters {
+		for _, v := range []uint64{b.m, b.k, b.n, b.capacity} {
+			if err := binary.Write(cw, binary.LittleEndian, v); err != nil {
+				return cw.n, err
+			}
+		}
+		if err := binary.Write(cw, binary.LittleEndian, b.bits); err != nil {
+			return cw.n, err
+		}
+	}
+
+	return cw.n, nil
+}
+
+// Read a filter written by WriteTo, size is the length of the data in r.
+// sizes read from the data are checked against it before allocating.
+func Read(r io.Reader, size int64) (*Filter, error) {
+	var (
+		mg    [4]byte
+		fp    uint64
+		count uint64
+	)
+
+	cr := &countReader{r: r}
+	for _, v := range []interface{}{&mg, &fp, &count} {
+		if err := binary.Read(cr, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+
+	// each filter takes at least its 4 header words
+	if mg != magic || count == 0 || count > cr.left(size)/32 {
+		return nil, ErrInvalid
+	}
+
+	f := &Filter{
+		mu: &sync.RWMutex{},
+		fp: math.Float64frombits(fp),
+	}
+
+	for i := uint64(0); i < count; i++ {
+		b := &filter{}
+		for _, v := range []*uint64{&b.m, &b.k, &b.n, &b.capacity} {
+			if err := binary.Read(cr, binary.LittleEndian, v); err != nil {
+				return nil, err
+			}
+		}
+
+		if b.m == 0 || b.k == 0 || b.m > cr.left(size)*8 {
+			return nil, ErrInvalid
+		}
+
+		b.bits = make([]uint64, (b.m+63)/64)
+		if err := binary.Read(cr, binary.LittleEndian, b.bits); err != nil {
+			return nil, err
+		}
+
+		f.filters = append(f.filters, b)
+	}
+
+	return f, nil
+}
+
+// add
+func (b *filter) add(h1, h2 uint64) {
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	b.n++
+}
+
+// test
+func (b *filter) test(h1, h2 uint64) bool {
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash: the two halves of a 128-bit hash for double hashing.
+func hash(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+
+	// h2 must be odd to reach every bit
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// countReader
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// left: bytes of size not read yet.
+func (c *countReader) left(size int64) uint64 {
+	if size < c.n {
+		return 0
+	}
+	return uint64(size - c.n)
+}
+
+// countWriter
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
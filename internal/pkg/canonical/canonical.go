@@ -0,0 +1,147 @@
+package canonical
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// default ports by scheme
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Canonicalizer rewrites urls that point to the same page to a single form.
+type Canonicalizer struct {
+	params   map[string]bool // exact param names
+	prefixes []string        // param name prefixes, from patterns ending with *
+}
+
+// New: strip lists query params to remove, a trailing * matches
+// any param starting with the prefix, e.g. utm_*.
+func New(strip []string) *Canonicalizer {
+	c := &Canonicalizer{
+		params: map[string]bool{},
+	}
+
+	for _, p := range strip {
+		p = strings.ToLower(strings.TrimSpace(p))
+		switch {
+		case p == "":
+		case strings.HasSuffix(p, "*"):
+			c.prefixes = append(c.prefixes, strings.TrimSuffix(p, "*"))
+		default:
+			c.params[p] = true
+		}
+	}
+
+	return c
+}
+
+// String returns the canonical form of raw.
+func (c *Canonicalizer) String(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return c.URL(u).String(), nil
+}
+
+// URL returns a canonical copy of u: lower case scheme and host, no default
+// port, no fragment, no dot segments nor trailing slash, stripped params
+// removed and remaining params sorted.
+func (c *Canonicalizer) URL(u *url.URL) *url.URL {
+	cu := *u
+	cu.User = nil
+	cu.Scheme = strings.ToLower(cu.Scheme)
+	cu.Fragment = ""
+	cu.RawFragment = ""
+
+	host := strings.TrimSuffix(strings.ToLower(cu.Hostname()), ".")
+	if strings.Contains(host, ":") {
+		// ipv6
+		host = "[" + host + "]"
+	}
+	if port := cu.Port(); port != "" && port != defaultPorts[cu.Scheme] {
+		host += ":" + port
+	}
+	cu.Host = host
+
+	cu.Path = cleanPath(cu.Path)
+	cu.RawPath = ""
+
+	cu.RawQuery = c.query(cu.RawQuery)
+	cu.ForceQuery = false
+
+	return &cu
+}
+
+// cleanPath resolves dot segments and removes the trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	p = path.Clean("/" + p)
+	if p != "/" {
+		p = strings.TrimSuffix(p, "/")
+	}
+
+	return p
+}
+
+// query removes stripped params and sorts the rest by name,
+// params with the same name keep their order.
+func (c *Canonicalizer) query(raw string) string {
+	if raw == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		// keep an unparsable query as is
+		return raw
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if c.strip(name) {
+			delete(values, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, v := range values[name] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(name))
+			if v != "" {
+				b.WriteByte('=')
+				b.WriteString(url.QueryEscape(v))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// strip
+func (c *Canonicalizer) strip(name string) bool {
+	name = strings.ToLower(name)
+	if c.params[name] {
+		return true
+	}
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package cookies
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpOnly prefix of http only cookies in curl and browser exports.
+const httpOnly = "#HttpOnly_"
+
+// Load the cookies of a Netscape cookies.txt file into jar,
+// expired cookies are skipped.
+func Load(jar http.CookieJar, fp string) error {
+	f, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return Read(jar, f)
+}
+
+// Read cookies in the Netscape cookies.txt format into jar, one per line:
+// domain, include subdomains, path, secure, expires, name and value
+// separated by tabs.
+func Read(jar http.CookieJar, r io.Reader) error {
+	var (
+		now  = time.Now()
+		line int
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+
+		var only bool
+		if strings.HasPrefix(text, httpOnly) {
+			text = strings.TrimPrefix(text, httpOnly)
+			only = true
+		}
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) == 6 {
+			// a cookie without value
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return fmt.Errorf("cookies: line %d: want 7 tab separated fields, got %d", line, len(fields))
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("cookies: line %d: invalid expiry %q", line, fields[4])
+		}
+
+		c := &http.Cookie{
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: only,
+		}
+
+		// 0 is a session cookie
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+			if c.Expires.Before(now) {
+				continue
+			}
+		}
+
+		host := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			c.Domain = host
+		}
+
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: c.Path}, []*http.Cookie{c})
+	}
+
+	return scanner.Err()
+}
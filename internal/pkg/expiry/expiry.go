@@ -0,0 +1,85 @@
+package expiry
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// expiry date lines found in common WHOIS responses
+	dateRegexp = regexp.MustCompile(`(?im)^\s*(?:registry expiry date|registrar registration expiration date|expiration date|expiry date|expiration time|expires on|expires|expire date|paid-till|valid until|renewal date)\s*[:.]*\s*(.+?)\s*$`)
+
+	// EPP status codes
+	redemptionRegexp    = regexp.MustCompile(`(?i)redemptionPeriod`)
+	pendingDeleteRegexp = regexp.MustCompile(`(?i)pendingDelete`)
+	graceRegexp         = regexp.MustCompile(`(?i)autoRenewPeriod`)
+)
+
+// date layouts used by WHOIS servers
+var layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05.0Z",
+	"2006-01-02T15:04:05.00Z",
+	"2006-01-02T15:04:05.000Z",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02",
+	"2006.01.02",
+	"2006/01/02",
+	"02-Jan-2006",
+	"02.01.2006",
+	"02/01/2006",
+	"January 2 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+}
+
+// Phases
+const (
+	Active        = "active"
+	Expiring      = "expiring"
+	Grace         = "grace"
+	Redemption    = "redemption"
+	PendingDelete = "pending_delete"
+)
+
+// Parse finds the expiry date in a raw WHOIS response.
+func Parse(raw string) (time.Time, bool) {
+	for _, m := range dateRegexp.FindAllStringSubmatch(raw, -1) {
+		value := strings.TrimSpace(m[1])
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Phase of a registered domain from its WHOIS response and expiry date.
+// window is how long before expiry a domain is considered expiring.
+func Phase(raw string, exp time.Time, window time.Duration, now time.Time) string {
+	switch {
+	case pendingDeleteRegexp.MatchString(raw):
+		return PendingDelete
+	case redemptionRegexp.MatchString(raw):
+		return Redemption
+	case graceRegexp.MatchString(raw):
+		return Grace
+	case exp.IsZero():
+		return Active
+	case !now.Before(exp):
+		return Grace
+	case exp.Sub(now) <= window:
+		return Expiring
+	default:
+		return Active
+	}
+}
+
+// Dropping reports whether a phase is after expiry.
+func Dropping(phase string) bool {
+	return phase == Grace || phase == Redemption || phase == PendingDelete
+}
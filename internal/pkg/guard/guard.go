@@ -0,0 +1,406 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Errs
+var (
+	ErrBlocked = errors.New("blocked address")
+)
+
+// reserved: private, loopback, link-local, multicast and special purpose
+// ranges a crawler has no business connecting to.
+var reserved = cidrs(
+	// IPv4
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10", // carrier-grade NAT
+	"127.0.0.0/8",
+	"169.254.0.0/16", // link-local, cloud metadata
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.88.99.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	// IPv6
+	"::/128",
+	"::1/128",
+	"::ffff:0:0:0/96",
+	"100::/64",
+	"2001::/23",
+	"2001:db8::/32",
+	"fc00::/7",
+	"fe80::/10",
+	"fec0::/10",
+	"ff00::/8",
+)
+
+// proxyPorts: default port of a proxy url by scheme, as the transport.
+var proxyPorts = map[string]string{
+	"http":    "80",
+	"https":   "443",
+	"socks5":  "1080",
+	"socks5h": "1080",
+}
+
+// embedding: IPv6 ranges carrying an IPv4 address, checked as such.
+var (
+	nat64  = cidrs("64:ff9b::/96", "64:ff9b:1::/48")
+	sixto4 = cidrs("2002::/16")
+)
+
+// Config
+type Config struct {
+	// Allow: ip ranges (10.0.0.0/8), ips and host patterns
+	// (*.internal.test) connections are allowed to anyway.
+	Allow []string
+}
+
+// Guard rejects connections to reserved ip ranges. the check runs at dial
+// time, on the resolved address, so redirects and host names resolving to
+// internal addresses are covered.
+type Guard struct {
+	blocked uint64 // first for 64-bit atomic alignment
+	nets    []*net.IPNet
+	hosts   []string
+}
+
+// hopKey: context key of the proxy hop of a request.
+type hopKey struct{}
+
+// hop: host:port of the proxy a request goes through, set by the proxy
+// func of the transport before the connection to it is dialed.
+type hop struct {
+	addr atomic.Value // string
+}
+
+// New
+func New(conf Config) (*Guard, error) {
+	g := &Guard{}
+
+	for _, a := range conf.Allow {
+		a = strings.ToLower(strings.TrimSpace(a))
+		switch {
+		case a == "":
+			continue
+		case strings.Contains(a, "/"):
+			_, n, err := net.ParseCIDR(a)
+			if err != nil {
+				return nil, fmt.Errorf("guard: invalid allowed range %q", a)
+			}
+			g.nets = append(g.nets, n)
+		case net.ParseIP(a) != nil:
+			g.nets = append(g.nets, single(net.ParseIP(a)))
+		default:
+			if _, err := path.Match(a, ""); err != nil {
+				return nil, fmt.Errorf("guard: invalid allowed host %q", a)
+			}
+			g.hosts = append(g.hosts, a)
+		}
+	}
+
+	return g, nil
+}
+
+// Blocked returns the number of rejected connections.
+func (g *Guard) Blocked() uint64 {
+	if g == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&g.blocked)
+}
+
+// Dial returns the DialContext of d, guarded. a nil Guard
+// does not check anything.
+func (g *Guard) Dial(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if g == nil {
+		return d.DialContext
+	}
+
+	guarded := *d
+	control := d.Control
+	guarded.Control = func(network, address string, c syscall.RawConn) error {
+		if err := g.control(address); err != nil {
+			return err
+		}
+		if control != nil {
+			return control(network, address, c)
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err == nil && (proxyHop(ctx, addr) || g.allowedHost(host)) {
+			return d.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+}
+
+// Proxy wraps the proxy func of a transport, the target of a proxied
+// request is checked before it is sent. the connection to the proxy
+// is not checked when the transport is wrapped by Wrap.
+func (g *Guard) Proxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	if g == nil || proxy == nil {
+		return proxy
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil {
+			return u, err
+		}
+		if err := g.Check(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		if h, ok := req.Context().Value(hopKey{}).(*hop); ok {
+			h.addr.Store(proxyAddr(u.String()))
+		}
+		return u, nil
+	}
+}
+
+// Wrap rt so the proxy hop of each request is known when it is dialed,
+// rt must use the Proxy and Dial funcs of g.
+func (g *Guard) Wrap(rt http.RoundTripper) http.RoundTripper {
+	if g == nil {
+		return rt
+	}
+	return &transport{rt: rt}
+}
+
+// Transport: http.DefaultTransport, guarded.
+func (g *Guard) Transport() http.RoundTripper {
+	if g == nil {
+		return http.DefaultTransport
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = g.Proxy(t.Proxy)
+	t.DialContext = g.Dial(&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	})
+
+	return g.Wrap(t)
+}
+
+// transport
+type transport struct {
+	rt http.RoundTripper
+}
+
+// RoundTrip
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := context.WithValue(req.Context(), hopKey{}, &hop{})
+	return t.rt.RoundTrip(req.WithContext(ctx))
+}
+
+// CloseIdleConnections
+func (t *transport) CloseIdleConnections() {
+	if c, ok := t.rt.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// Check host before a request is sent through a proxy, which resolves it
+// on its own: ips and the addresses host resolves to here are checked.
+// a host that does not resolve is left to the proxy.
+func (g *Guard) Check(ctx context.Context, host string) error {
+	if g == nil || g.allowedHost(host) {
+		return nil
+	}
+
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return g.check(ip)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+
+	for _, addr := range addrs {
+		if err := g.check(addr.IP); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reserved reports whether ip is in a reserved range.
+func Reserved(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	if v4 := embedded(ip); v4 != nil {
+		return Reserved(v4)
+	}
+
+	for _, n := range reserved {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// control: address is the resolved ip:port about to be connected to.
+func (g *Guard) control(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrBlocked, host)
+	}
+
+	return g.check(ip)
+}
+
+// check
+func (g *Guard) check(ip net.IP) error {
+	if !Reserved(ip) || g.allowedIP(ip) {
+		return nil
+	}
+
+	atomic.AddUint64(&g.blocked, 1)
+	return fmt.Errorf("%w: %s", ErrBlocked, ip)
+}
+
+// proxyHop reports whether addr, a host:port about to be dialed,
+// is the proxy of the request of ctx.
+func proxyHop(ctx context.Context, addr string) bool {
+	h, ok := ctx.Value(hopKey{}).(*hop)
+	if !ok {
+		return false
+	}
+
+	proxy, _ := h.addr.Load().(string)
+	if proxy == "" {
+		return false
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	return proxy == net.JoinHostPort(strings.ToLower(host), port)
+}
+
+// allowedHost: allowed host patterns and ips.
+func (g *Guard) allowedHost(host string) bool {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	if ip := net.ParseIP(host); ip != nil {
+		return g.allowedIP(ip)
+	}
+
+	for _, pattern := range g.hosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedIP
+func (g *Guard) allowedIP(ip net.IP) bool {
+	for _, n := range g.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyAddr: host:port of a proxy url, the port defaults by scheme.
+func proxyAddr(proxy string) string {
+	if proxy == "" {
+		return ""
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		u, err = url.Parse("http://" + proxy)
+	}
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = proxyPorts[strings.ToLower(u.Scheme)]
+	}
+	if port == "" {
+		return ""
+	}
+
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// embedded returns the IPv4 address carried by a NAT64 or 6to4 address.
+func embedded(ip net.IP) net.IP {
+	if len(ip) != net.IPv6len {
+		return nil
+	}
+
+	for _, n := range nat64 {
+		if n.Contains(ip) {
+			return net.IP(ip[12:16])
+		}
+	}
+
+	for _, n := range sixto4 {
+		if n.Contains(ip) {
+			return net.IP(ip[2:6])
+		}
+	}
+
+	return nil
+}
+
+// single: the range of ip alone.
+func single(ip net.IP) *net.IPNet {
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// cidrs
+func cidrs(list ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
@@ -0,0 +1,99 @@
+package lru
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache: a fixed size, concurrency safe, least recently used cache.
+type Cache[K comparable, V any] struct {
+	mu    *sync.Mutex
+	size  int
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+// entry
+type entry[K comparable, V any] struct {
+	key K
+	val V
+}
+
+// New: a size of 0 or less means no limit.
+func New[K comparable, V any](size int) *Cache[K, V] {
+	return &Cache[K, V]{
+		mu:    &sync.Mutex{},
+		size:  size,
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+// Get
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.items[key]; found {
+		c.ll.MoveToFront(e)
+		return e.Value.(*entry[K, V]).val, true
+	}
+
+	var v V
+	return v, false
+}
+
+// Add or update a value, it returns true if an entry was evicted.
+func (c *Cache[K, V]) Add(key K, val V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.items[key]; found {
+		c.ll.MoveToFront(e)
+		e.Value.(*entry[K, V]).val = val
+		return false
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, val: val})
+
+	if c.size > 0 && c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry[K, V]).key)
+		return true
+	}
+
+	return false
+}
+
+// Remove
+func (c *Cache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.items[key]; found {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// Range calls fn for each entry from most to least recently used
+// until fn returns false. fn must not modify the cache.
+func (c *Cache[K, V]) Range(fn func(key K, val V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		item := e.Value.(*entry[K, V])
+		if !fn(item.key, item.val) {
+			return
+		}
+	}
+}
+
+// Len
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
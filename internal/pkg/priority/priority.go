@@ -0,0 +1,129 @@
+package priority
+
+import (
+	"math"
+	"net/url"
+	"strings"
+)
+
+// Weights: relative weight of each priority factor.
+type Weights struct {
+	Yield     float64 // new domains found on the parent page
+	Directory float64 // how much the page looks like a link list
+	Depth     float64 // penalty per level of depth
+	Diversity float64 // penalty for hosts already crawled a lot
+}
+
+// DefaultWeights
+var DefaultWeights = Weights{
+	Yield:     3,
+	Directory: 1,
+	Depth:     0.5,
+	Diversity: 1,
+}
+
+// DefaultKeywords: url words of link lists, blogrolls and resource pages.
+var DefaultKeywords = []string{
+	"links",
+	"blogroll",
+	"resources",
+	"directory",
+	"partners",
+	"friends",
+	"sites",
+	"sponsors",
+	"webring",
+	"archive",
+}
+
+// Page: what the parent page of a url yielded.
+type Page struct {
+	Domains int // external domains linked
+	New     int // domains never seen before
+}
+
+// Input
+type Input struct {
+	URL       *url.URL
+	Depth     int32
+	Parent    Page // zero for seeds and unknown parents
+	HostPages int  // pages already fetched from the url host
+}
+
+// Scorer ranks urls by how likely they are to yield new domains,
+// higher is better.
+type Scorer struct {
+	weights  Weights
+	keywords []string
+}
+
+// NewScorer: no keywords means DefaultKeywords.
+func NewScorer(w Weights, keywords []string) *Scorer {
+	if len(keywords) == 0 {
+		keywords = DefaultKeywords
+	}
+
+	kws := make([]string, 0, len(keywords))
+	for _, k := range keywords {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			kws = append(kws, k)
+		}
+	}
+
+	return &Scorer{
+		weights:  w,
+		keywords: kws,
+	}
+}
+
+// Score
+func (s *Scorer) Score(in Input) float64 {
+	yield := math.Log1p(float64(in.Parent.New))
+
+	// a parent linking many domains is a list, so are its pages
+	directory := math.Log1p(float64(in.Parent.Domains)) / 2
+	if s.keyword(in.URL) {
+		directory += 1
+	}
+
+	depth := float64(in.Depth)
+	crawled := math.Log1p(float64(in.HostPages))
+
+	return s.weights.Yield*yield +
+		s.weights.Directory*directory -
+		s.weights.Depth*depth -
+		s.weights.Diversity*crawled
+}
+
+// keyword reports whether the url path contains a keyword.
+func (s *Scorer) keyword(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+
+	p := strings.ToLower(u.Path)
+	for _, k := range s.keywords {
+		if strings.Contains(p, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWeights overrides DefaultWeights with the given factors.
+func ParseWeights(m map[string]float64) Weights {
+	w := DefaultWeights
+	for k, v := range m {
+		switch strings.ToLower(k) {
+		case "yield":
+			w.Yield = v
+		case "directory":
+			w.Directory = v
+		case "depth":
+			w.Depth = v
+		case "diversity":
+			w.Diversity = v
+		}
+	}
+	return w
+}
@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"bufio"
+	"math/rand"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+)
+
+// rotation strategies
+const (
+	RoundRobin = "round_robin"
+	Random     = "random"
+	Sticky     = "sticky" // a host keeps its proxy while it is healthy
+)
+
+// defaults
+const (
+	defaultMaxErrors = 3
+	defaultEvict     = 5 * time.Minute
+	maxHosts         = 100000 // sticky hosts kept
+)
+
+// Config
+type Config struct {
+	Strategy  string
+	MaxErrors int           // errors in a row before a proxy is evicted
+	Evict     time.Duration // how long an evicted proxy is left out
+}
+
+// Stat: counters of a proxy.
+type Stat struct {
+	Proxy     string // credentials redacted
+	Requests  uint64
+	Errors    uint64
+	Evictions uint64
+	Evicted   bool // left out now
+}
+
+// entry
+type entry struct {
+	proxy   string
+	stat    Stat
+	strikes int       // errors in a row
+	until   time.Time // evicted until
+}
+
+// Pool rotates requests over proxies, failing proxies are left out
+// for a while.
+type Pool struct {
+	mu      *sync.Mutex
+	conf    Config
+	entries []*entry
+	index   map[string]*entry
+	next    int                        // round robin position
+	hosts   *lru.Cache[string, string] // sticky proxy by host
+	rand    *rand.Rand
+}
+
+// New
+func New(conf Config, proxies []string) *Pool {
+	if conf.MaxErrors <= 0 {
+		conf.MaxErrors = defaultMaxErrors
+	}
+	if conf.Evict <= 0 {
+		conf.Evict = defaultEvict
+	}
+
+	p := &Pool{
+		mu:    &sync.Mutex{},
+		conf:  conf,
+		index: map[string]*entry{},
+		hosts: lru.New[string, string](maxHosts),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	p.Set(proxies)
+
+	return p
+}
+
+// Set replaces the proxies, the counters of kept proxies are kept.
+func (p *Pool) Set(proxies []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		entries = make([]*entry, 0, len(proxies))
+		index   = make(map[string]*entry, len(proxies))
+	)
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" || index[proxy] != nil {
+			continue
+		}
+
+		e, found := p.index[proxy]
+		if !found {
+			e = &entry{proxy: proxy, stat: Stat{Proxy: redact(proxy)}}
+		}
+		entries = append(entries, e)
+		index[proxy] = e
+	}
+
+	p.entries = entries
+	p.index = index
+	if p.next >= len(entries) {
+		p.next = 0
+	}
+}
+
+// Len
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Pick a proxy for a request to host, "" if there are none. if every
+// proxy is evicted the one back first is used.
+func (p *Pool) Pick(host string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.entries) == 0 {
+		return ""
+	}
+
+	now := time.Now()
+
+	if p.conf.Strategy == Sticky {
+		if proxy, found := p.hosts.Get(host); found {
+			if e, ok := p.index[proxy]; ok && !e.evicted(now) {
+				return e.proxy
+			}
+		}
+	}
+
+	var healthy []*entry
+	for _, e := range p.entries {
+		if !e.evicted(now) {
+			healthy = append(healthy, e)
+		}
+	}
+
+	var e *entry
+	switch {
+	case len(healthy) == 0:
+		e = p.entries[0]
+		for _, o := range p.entries[1:] {
+			if o.until.Before(e.until) {
+				e = o
+			}
+		}
+	case p.conf.Strategy == Random || p.conf.Strategy == Sticky:
+		e = healthy[p.rand.Intn(len(healthy))]
+	default:
+		// the next healthy proxy in order
+		for i := 0; i < len(p.entries); i++ {
+			o := p.entries[(p.next+i)%len(p.entries)]
+			if !o.evicted(now) {
+				e = o
+				p.next = (p.next + i + 1) % len(p.entries)
+				break
+			}
+		}
+	}
+
+	if p.conf.Strategy == Sticky {
+		p.hosts.Add(host, e.proxy)
+	}
+
+	return e.proxy
+}
+
+// Report the outcome of a request through proxy, err is nil on success.
+func (p *Pool) Report(proxy string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, found := p.index[proxy]
+	if !found {
+		return
+	}
+
+	e.stat.Requests++
+	if err == nil {
+		e.strikes = 0
+		return
+	}
+
+	e.stat.Errors++
+	e.strikes++
+	if e.strikes >= p.conf.MaxErrors {
+		e.strikes = 0
+		e.until = time.Now().Add(p.conf.Evict)
+		e.stat.Evictions++
+	}
+}
+
+// Stats of each proxy.
+func (p *Pool) Stats() []Stat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]Stat, 0, len(p.entries))
+	for _, e := range p.entries {
+		st := e.stat
+		st.Evicted = e.evicted(now)
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// evicted
+func (e *entry) evicted(now time.Time) bool {
+	return now.Before(e.until)
+}
+
+// ReadFile reads one proxy per line, blank lines and lines
+// starting with # are skipped.
+func ReadFile(fp string) ([]string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var proxies []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+
+	return proxies, scanner.Err()
+}
+
+// redact the credentials of a proxy url.
+func redact(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return proxy
+	}
+	return u.Redacted()
+}
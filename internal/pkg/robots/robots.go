@@ -0,0 +1,218 @@
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots: the rules of a robots.txt file.
+type Robots struct {
+	groups   []*group
+	Sitemaps []string
+}
+
+// group: rules shared by one or more user agents.
+type group struct {
+	agents []string
+	rules  []rule
+	delay  time.Duration
+}
+
+// rule
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// AllowAll: used when a host has no robots.txt.
+func AllowAll() *Robots {
+	return &Robots{}
+}
+
+// DisallowAll: used when robots.txt can not be fetched.
+func DisallowAll() *Robots {
+	return &Robots{
+		groups: []*group{{
+			agents: []string{"*"},
+			rules:  []rule{{pattern: "/"}},
+		}},
+	}
+}
+
+// Parse a robots.txt body, invalid lines are ignored.
+func Parse(body []byte) *Robots {
+	var (
+		r       = &Robots{}
+		current *group
+		inRules bool // a rule was read since the last user-agent line
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &group{}
+				r.groups = append(r.groups, current)
+				inRules = false
+			}
+			// a version or comment after the product token is ignored
+			current.agents = append(current.agents, Token(val))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			// an empty disallow allows everything
+			if val == "" {
+				continue
+			}
+			current.rules = append(current.rules, rule{
+				allow:   key == "allow",
+				pattern: val,
+			})
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if sec, err := strconv.ParseFloat(val, 64); err == nil && sec > 0 {
+				current.delay = time.Duration(sec * float64(time.Second))
+			}
+		case "sitemap":
+			r.Sitemaps = append(r.Sitemaps, val)
+		}
+	}
+
+	return r
+}
+
+// Allowed reports whether agent may fetch path, path includes the query.
+// the longest matching rule wins, allow wins a tie.
+func (r *Robots) Allowed(agent, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	var (
+		allowed = true
+		longest = -1
+	)
+
+	for _, g := range r.match(agent) {
+		for _, rl := range g.rules {
+			if !match(rl.pattern, path) {
+				continue
+			}
+
+			n := len(rl.pattern)
+			if n > longest || (n == longest && rl.allow) {
+				longest = n
+				allowed = rl.allow
+			}
+		}
+	}
+
+	return allowed
+}
+
+// Delay returns the crawl-delay for agent, 0 if none.
+func (r *Robots) Delay(agent string) time.Duration {
+	var delay time.Duration
+	for _, g := range r.match(agent) {
+		if g.delay > delay {
+			delay = g.delay
+		}
+	}
+	return delay
+}
+
+// match returns the groups of agent, or the groups of * if none.
+// product tokens match exactly, case-insensitively (RFC 9309).
+func (r *Robots) match(agent string) []*group {
+	token := Token(agent)
+
+	var named, star []*group
+	for _, g := range r.groups {
+		switch {
+		case g.has(func(a string) bool { return token != "" && a == token }):
+			named = append(named, g)
+		case g.has(func(a string) bool { return a == "*" }):
+			star = append(star, g)
+		}
+	}
+
+	if len(named) > 0 {
+		return named
+	}
+	return star
+}
+
+// has reports whether one of the group agents satisfies fn.
+func (g *group) has(fn func(agent string) bool) bool {
+	for _, a := range g.agents {
+		if a != "" && fn(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Token returns the product token of a user agent,
+// e.g. spidy for "Spidy/2.1; +https://github.com/twiny/spidy".
+func Token(agent string) string {
+	agent = strings.TrimSpace(agent)
+	if i := strings.IndexAny(agent, "/ ;("); i >= 0 {
+		agent = agent[:i]
+	}
+	return strings.ToLower(agent)
+}
+
+// match path against a pattern, * matches any sequence
+// and a trailing $ anchors the end of the path.
+func match(pattern, path string) bool {
+	end := strings.HasSuffix(pattern, "$")
+	if end {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		// the last part of an anchored pattern must end the path
+		if end && i == len(parts)-2 {
+			return len(path)-len(part) >= pos && strings.HasSuffix(path, part)
+		}
+
+		j := strings.Index(path[pos:], part)
+		if j < 0 {
+			return false
+		}
+		pos += j + len(part)
+	}
+
+	return !end || pos == len(path)
+}
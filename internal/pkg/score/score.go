@@ -0,0 +1,256 @@
+package score
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Weights: relative weight of each scoring factor.
+type Weights struct {
+	Length    float64
+	Words     float64
+	TLD       float64
+	Hyphens   float64
+	Digits    float64
+	Referrers float64
+	Pronounce float64
+}
+
+// DefaultWeights
+var DefaultWeights = Weights{
+	Length:    2,
+	Words:     3,
+	TLD:       2,
+	Hyphens:   1,
+	Digits:    1,
+	Referrers: 2,
+	Pronounce: 1,
+}
+
+// DefaultTLDs: preference of well known extensions, others score 0.
+var DefaultTLDs = map[string]float64{
+	"com": 1,
+	"io":  0.7,
+	"net": 0.6,
+	"org": 0.6,
+	"co":  0.5,
+}
+
+const (
+	minWordLen = 3
+	vowels     = "aeiouy"
+)
+
+// factor
+type factor struct {
+	weight float64
+	value  float64
+}
+
+// Scorer rates a domain from 0 to 100.
+type Scorer struct {
+	words   map[string]bool
+	maxWord int
+	tlds    map[string]float64
+	weights Weights
+}
+
+// NewScorer: words is an optional path to a word list (one word per line).
+func NewScorer(words string, tlds map[string]float64, w Weights) (*Scorer, error) {
+	s := &Scorer{
+		words:   map[string]bool{},
+		tlds:    tlds,
+		weights: w,
+	}
+
+	if len(s.tlds) == 0 {
+		s.tlds = DefaultTLDs
+	}
+
+	if words == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(words)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if len(word) < minWordLen || strings.HasPrefix(word, "#") {
+			continue
+		}
+		s.words[word] = true
+		if len(word) > s.maxWord {
+			s.maxWord = len(word)
+		}
+	}
+
+	return s, scanner.Err()
+}
+
+// Score
+func (s *Scorer) Score(name, tld string, pages int) float64 {
+	name = strings.ToLower(name)
+
+	var factors = []factor{
+		{s.weights.Length, length(name)},
+		{s.weights.TLD, s.tlds[strings.ToLower(tld)]},
+		{s.weights.Hyphens, penalty(strings.Count(name, "-"), 0.5)},
+		{s.weights.Digits, penalty(countDigits(name), 0.25)},
+		{s.weights.Referrers, referrers(pages)},
+		{s.weights.Pronounce, pronounce(name)},
+	}
+
+	// dictionary is optional
+	if len(s.words) > 0 {
+		factors = append(factors, factor{s.weights.Words, s.dictionary(name)})
+	}
+
+	var total, sum float64
+	for _, f := range factors {
+		if f.weight <= 0 {
+			continue
+		}
+		total += f.weight
+		sum += f.weight * f.value
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return math.Round(sum/total*10000) / 100
+}
+
+// dictionary: share of the name covered by dictionary words,
+// names made of one or two words score best.
+func (s *Scorer) dictionary(name string) float64 {
+	letters := strings.ReplaceAll(name, "-", "")
+	if letters == "" {
+		return 0
+	}
+
+	// best[i]: max covered letters and words used for letters[:i]
+	type cover struct{ letters, words int }
+	best := make([]cover, len(letters)+1)
+
+	for i := 1; i <= len(letters); i++ {
+		// skip a letter
+		best[i] = best[i-1]
+
+		for j := i - minWordLen; j >= 0 && i-j <= s.maxWord; j-- {
+			if !s.words[letters[j:i]] {
+				continue
+			}
+			c := cover{best[j].letters + i - j, best[j].words + 1}
+			if c.letters > best[i].letters || (c.letters == best[i].letters && c.words < best[i].words) {
+				best[i] = c
+			}
+		}
+	}
+
+	c := best[len(letters)]
+	if c.words == 0 {
+		return 0
+	}
+
+	v := float64(c.letters) / float64(len(letters))
+	if c.words > 2 {
+		v *= 2 / float64(c.words)
+	}
+	return v
+}
+
+// length: 4 letters or less is best, 20 or more is worst.
+func length(name string) float64 {
+	return clamp(1 - float64(len(name)-4)/16)
+}
+
+// penalty
+func penalty(n int, step float64) float64 {
+	return clamp(1 - float64(n)*step)
+}
+
+// referrers: log scale, 1000 pages or more is best.
+func referrers(pages int) float64 {
+	if pages <= 0 {
+		return 0
+	}
+	return clamp(math.Log10(float64(1+pages)) / 3)
+}
+
+// pronounce: rough pronounceability from vowel ratio
+// and long consonant clusters.
+func pronounce(name string) float64 {
+	var letters, vowel, run, clusters int
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			run = 0
+			continue
+		}
+		letters++
+		if strings.ContainsRune(vowels, r) {
+			vowel++
+			run = 0
+			continue
+		}
+		run++
+		if run == 4 {
+			clusters++
+		}
+	}
+
+	if letters == 0 {
+		return 0
+	}
+
+	ratio := float64(vowel) / float64(letters)
+	return clamp(1-math.Abs(ratio-0.4)*2) * penalty(clusters, 0.25)
+}
+
+// countDigits
+func countDigits(s string) int {
+	var n int
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// clamp to [0, 1]
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// ParseWeights overrides DefaultWeights with the given factors.
+func ParseWeights(m map[string]float64) Weights {
+	w := DefaultWeights
+	for k, v := range m {
+		switch strings.ToLower(k) {
+		case "length":
+			w.Length = v
+		case "words":
+			w.Words = v
+		case "tld":
+			w.TLD = v
+		case "hyphens":
+			w.Hyphens = v
+		case "digits":
+			w.Digits = v
+		case "referrers":
+			w.Referrers = v
+		case "pronounce":
+			w.Pronounce = v
+		}
+	}
+	return w
+}
@@ -0,0 +1,139 @@
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed default.txt
+var defaults []byte
+
+// sections
+const (
+	sectionNameservers  = "nameservers"
+	sectionMarketplaces = "marketplaces"
+	sectionForSale      = "for_sale"
+	sectionParked       = "parked"
+)
+
+// Set: parking and domain sale signatures.
+type Set struct {
+	nameservers  []string
+	marketplaces []string
+	forSale      [][]byte
+	parked       [][]byte
+}
+
+// Default: the built-in signatures.
+func Default() *Set {
+	s := &Set{}
+	// the embedded file is known to be valid
+	_ = s.read(bytes.NewReader(defaults), "default")
+	return s
+}
+
+// Load the built-in signatures and the given files, each has
+// [nameservers], [marketplaces], [for_sale] and [parked] sections.
+func Load(files ...string) (*Set, error) {
+	s := Default()
+
+	for _, fp := range files {
+		f, err := os.Open(fp)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.read(f, fp)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// read a signature file.
+func (s *Set) read(r io.Reader, name string) error {
+	var (
+		section string
+		line    int
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+
+		text := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+
+		switch section {
+		case sectionNameservers:
+			s.nameservers = append(s.nameservers, strings.TrimSuffix(text, "."))
+		case sectionMarketplaces:
+			s.marketplaces = append(s.marketplaces, strings.TrimSuffix(text, "."))
+		case sectionForSale:
+			s.forSale = append(s.forSale, []byte(text))
+		case sectionParked:
+			s.parked = append(s.parked, []byte(text))
+		default:
+			return fmt.Errorf("%s:%d: entry outside of a known section", name, line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Nameserver returns the parking signature a name server matches.
+func (s *Set) Nameserver(ns string) (string, bool) {
+	return suffix(s.nameservers, ns)
+}
+
+// Marketplace returns the marketplace host is, or is a subdomain of.
+func (s *Set) Marketplace(host string) (string, bool) {
+	return suffix(s.marketplaces, host)
+}
+
+// ForSale returns the sale signature body contains.
+func (s *Set) ForSale(body []byte) (string, bool) {
+	return contains(s.forSale, body)
+}
+
+// Parked returns the parking signature body contains.
+func (s *Set) Parked(body []byte) (string, bool) {
+	return contains(s.parked, body)
+}
+
+// suffix: name equals or is a subdomain of a signature.
+func suffix(sigs []string, name string) (string, bool) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, sig := range sigs {
+		if name == sig || strings.HasSuffix(name, "."+sig) {
+			return sig, true
+		}
+	}
+	return "", false
+}
+
+// contains
+func contains(sigs [][]byte, body []byte) (string, bool) {
+	body = bytes.ToLower(body)
+	for _, sig := range sigs {
+		if bytes.Contains(body, sig) {
+			return string(sig), true
+		}
+	}
+	return "", false
+}
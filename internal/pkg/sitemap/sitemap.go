@@ -0,0 +1,196 @@
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// limits
+const (
+	maxSize  = 50 * 1024 * 1024 // uncompressed sitemap size
+	maxNests = 5                // nested sitemap indexes
+)
+
+// lastmod formats, W3C datetime
+var layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// URL: a sitemap entry.
+type URL struct {
+	Loc     string
+	LastMod time.Time // zero if unknown
+}
+
+// document: a urlset or a sitemap index.
+type document struct {
+	URLs     []entry `xml:"url"`
+	Sitemaps []entry `xml:"sitemap"`
+}
+
+// entry
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Parse returns the pages of a urlset and the sitemaps of an index,
+// gzip bodies are detected.
+func Parse(r io.Reader) ([]URL, []URL, error) {
+	br := bufio.NewReader(r)
+
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	var doc document
+	if err := xml.NewDecoder(io.LimitReader(src, maxSize)).Decode(&doc); err != nil {
+		return nil, nil, err
+	}
+
+	return urls(doc.URLs), urls(doc.Sitemaps), nil
+}
+
+// urls
+func urls(entries []entry) []URL {
+	list := make([]URL, 0, len(entries))
+	for _, e := range entries {
+		loc := strings.TrimSpace(e.Loc)
+		if loc == "" {
+			continue
+		}
+		list = append(list, URL{
+			Loc:     loc,
+			LastMod: parseLastMod(strings.TrimSpace(e.LastMod)),
+		})
+	}
+	return list
+}
+
+// parseLastMod
+func parseLastMod(s string) time.Time {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Getter fetches a sitemap, the caller closes the body.
+type Getter interface {
+	Get(ctx context.Context, link, userAgent string) (*http.Response, error)
+}
+
+// Reader fetches sitemaps and expands sitemap indexes.
+type Reader struct {
+	get       Getter
+	userAgent string
+	maxURLs   int // pages read in total, 0 means no limit
+	read      int
+}
+
+// NewReader
+func NewReader(get Getter, userAgent string, maxURLs int) *Reader {
+	return &Reader{
+		get:       get,
+		userAgent: userAgent,
+		maxURLs:   maxURLs,
+	}
+}
+
+// Read returns the pages listed by the sitemap at link. entries modified
+// before since are skipped, entries without lastmod are kept. the pages
+// read are returned along with the first error met. max urls is shared by
+// every sitemap read.
+func (r *Reader) Read(ctx context.Context, link string, since time.Time) ([]URL, error) {
+	var (
+		pages   []URL
+		first   error
+		visited = map[string]bool{}
+	)
+
+	var read func(link string, nest int) bool
+	read = func(link string, nest int) bool {
+		if visited[link] {
+			return true
+		}
+		visited[link] = true
+
+		list, indexes, err := r.fetch(ctx, link)
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("%s: %w", link, err)
+			}
+			return ctx.Err() == nil
+		}
+
+		for _, u := range list {
+			if !since.IsZero() && !u.LastMod.IsZero() && u.LastMod.Before(since) {
+				continue
+			}
+			if r.maxURLs > 0 && r.read >= r.maxURLs {
+				return false
+			}
+			pages = append(pages, u)
+			r.read++
+		}
+
+		if nest >= maxNests {
+			return true
+		}
+
+		for _, idx := range indexes {
+			// an unchanged sitemap lists no newer page
+			if !since.IsZero() && !idx.LastMod.IsZero() && idx.LastMod.Before(since) {
+				continue
+			}
+			if !read(idx.Loc, nest+1) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if r.maxURLs > 0 && r.read >= r.maxURLs {
+		return nil, nil
+	}
+
+	read(link, 0)
+
+	return pages, first
+}
+
+// fetch
+func (r *Reader) fetch(ctx context.Context, link string) ([]URL, []URL, error) {
+	resp, err := r.get.Get(ctx, link, r.userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("bad HTTP status %d", resp.StatusCode)
+	}
+
+	return Parse(resp.Body)
+}
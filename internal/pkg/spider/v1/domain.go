@@ -1,15 +1,104 @@
 package spider
 
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// homepage classes reported as the status of a registered domain
+const (
+	SiteParked  = "parked"
+	SiteForSale = "for_sale"
+)
+
 // Domain
 type Domain struct {
-	URL    string
-	Name   string
-	TLD    string
-	Status string
+	URL        string
+	Name       string
+	TLD        string
+	Status     string // of the check, see Reported
+	CheckedAt  time.Time
+	Referrers  Referrers
+	Score      float64
+	Expiry     time.Time
+	PrevStatus string // set on status change events
+	Cached     bool   // result comes from the store
+	Link       string // link to the domain found on URL
+	LinkError  string // why Link is broken, in dead link mode
+	Site       string // class of a registered domain homepage
+	SiteDetail string // matched signature or redirect target
+}
+
+// Columns: available result columns and how to render them.
+var Columns = map[string]func(d Domain) string{
+	"url":        func(d Domain) string { return d.URL },
+	"name":       func(d Domain) string { return d.Name },
+	"tld":        func(d Domain) string { return d.TLD },
+	"domain":     func(d Domain) string { return d.Name + "." + d.TLD },
+	"status":     func(d Domain) string { return d.Reported() },
+	"checked_at": func(d Domain) string { return FormatTime(d.CheckedAt) },
+	"timestamp": func(d Domain) string {
+		if d.CheckedAt.IsZero() {
+			return ""
+		}
+		return strconv.FormatInt(d.CheckedAt.Unix(), 10)
+	},
+	"pages":           func(d Domain) string { return strconv.Itoa(d.Referrers.Pages) },
+	"hosts":           func(d Domain) string { return strconv.Itoa(len(d.Referrers.Hosts)) },
+	"first_seen":      func(d Domain) string { return FormatTime(d.Referrers.FirstSeen) },
+	"last_seen":       func(d Domain) string { return FormatTime(d.Referrers.LastSeen) },
+	"anchors":         func(d Domain) string { return JoinAnchors(d.Referrers.Anchors) },
+	"score":           func(d Domain) string { return strconv.FormatFloat(d.Score, 'f', 2, 64) },
+	"expires_at":      func(d Domain) string { return FormatTime(d.Expiry) },
+	"previous_status": func(d Domain) string { return d.PrevStatus },
+	"cached":          func(d Domain) string { return strconv.FormatBool(d.Cached) },
+	"link":            func(d Domain) string { return d.Link },
+	"link_error":      func(d Domain) string { return d.LinkError },
+	"site":            func(d Domain) string { return d.Site },
+	"site_detail":     func(d Domain) string { return d.SiteDetail },
+}
+
+// Reported: the status written to results, registered domains whose
+// homepage is parked or for sale are reported as such.
+func (d Domain) Reported() string {
+	switch d.Site {
+	case SiteParked, SiteForSale:
+		return d.Site
+	}
+	return d.Status
+}
+
+// ValidColumns
+func ValidColumns(columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns")
+	}
+	for _, c := range columns {
+		if _, ok := Columns[c]; !ok {
+			return fmt.Errorf("unknown column %q", c)
+		}
+	}
+	return nil
+}
+
+// Row
+func (d Domain) Row(columns []string) []string {
+	row := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if fn, ok := Columns[c]; ok {
+			row = append(row, fn(d))
+			continue
+		}
+		row = append(row, "")
+	}
+	return row
 }
 
-// CSVRow
-func (d Domain) CSVRow() []string {
-	var row []string
-	return append(row, d.URL, d.Name, d.TLD, d.Status)
+// FormatTime: t as RFC 3339, empty if zero.
+func FormatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
 }
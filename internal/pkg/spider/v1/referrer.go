@@ -0,0 +1,81 @@
+package spider
+
+import (
+	"strings"
+	"time"
+)
+
+// limits of samples kept per domain
+const (
+	maxReferrerHosts = 100
+	maxAnchors       = 10
+)
+
+// anchorSep: separator of anchor texts in a column.
+const anchorSep = " | "
+
+// Referrers: aggregated sightings of a domain across crawled pages.
+type Referrers struct {
+	Pages     int       `json:"pages"`
+	Hosts     []string  `json:"hosts"` // distinct referring hosts
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Anchors   []string  `json:"anchors"` // sample anchor texts
+}
+
+// Merge adds o into r.
+func (r *Referrers) Merge(o Referrers) {
+	r.Pages += o.Pages
+
+	for _, h := range o.Hosts {
+		if len(r.Hosts) >= maxReferrerHosts {
+			break
+		}
+		if !contains(r.Hosts, h) {
+			r.Hosts = append(r.Hosts, h)
+		}
+	}
+
+	for _, a := range o.Anchors {
+		if len(r.Anchors) >= maxAnchors {
+			break
+		}
+		if a != "" && !contains(r.Anchors, a) {
+			r.Anchors = append(r.Anchors, a)
+		}
+	}
+
+	if !o.FirstSeen.IsZero() && (r.FirstSeen.IsZero() || o.FirstSeen.Before(r.FirstSeen)) {
+		r.FirstSeen = o.FirstSeen
+	}
+
+	if o.LastSeen.After(r.LastSeen) {
+		r.LastSeen = o.LastSeen
+	}
+}
+
+// JoinAnchors: anchor texts as a single column.
+func JoinAnchors(anchors []string) string {
+	return strings.Join(anchors, anchorSep)
+}
+
+// SplitAnchors: anchor texts of a column written by JoinAnchors.
+func SplitAnchors(s string) []string {
+	var anchors []string
+	for _, a := range strings.Split(s, anchorSep) {
+		if a = strings.TrimSpace(a); a != "" {
+			anchors = append(anchors, a)
+		}
+	}
+	return anchors
+}
+
+// contains
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
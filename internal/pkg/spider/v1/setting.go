@@ -2,6 +2,8 @@ package spider
 
 import (
 	"io/ioutil"
+	"os"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -34,6 +36,8 @@ var defaultSetting = &Setting{
 		MaxBodySize int64
 		UserAgents  []string
 		Proxies     []string
+		StripParams []string // query params removed from urls, e.g. utm_*
+		Extract     []int    // statuses of pages whose domains are extracted
 	}{
 		MaxDepth: 10,
 		Filter:   []string{},
@@ -47,6 +51,8 @@ var defaultSetting = &Setting{
 		MaxBodySize: 10 * 1024 * 1024, // 10 MB
 		UserAgents:  []string{`Spidy/2.1; +https://github.com/twiny/spidy`},
 		Proxies:     []string{},
+		StripParams: []string{"utm_*", "fbclid"},
+		Extract:     []int{200, 203, 206},
 	},
 	Log: struct {
 		Rotate int
@@ -56,14 +62,130 @@ var defaultSetting = &Setting{
 		Path:   "./log",
 	},
 	Store: struct {
-		TTL  time.Duration
-		Path string
+		Driver    string // badger, memory, bolt or sqlite
+		Size      int    // max entries of the memory driver
+		TTL       time.Duration
+		TTLs      map[string]time.Duration // ttl by status
+		WriteHits bool                     // write cached results
+		Bloom     bool                     // bloom filter in front of the store
+		BloomSize int                      // expected number of domains
+		BloomFP   float64                  // false positive rate
+		HotSize   int                      // records kept in memory
+		VisitTTL  time.Duration            // a visited url is not fetched again within VisitTTL
+		HTTPCache bool                     // conditional requests with stored validators
+		HTTPTTL   time.Duration            // how long the validators of a url are kept
+		Path      string
 	}{
-		TTL:  6 * time.Hour, // format: 1h, 1d, 1w, 1m - minimum 6h
-		Path: "./store",
+		Driver:    "badger",
+		Size:      1000000,
+		TTL:       6 * time.Hour, // format: 1h, 1d, 1w, 1m - minimum 6h
+		TTLs:      map[string]time.Duration{},
+		WriteHits: false,
+		Bloom:     true,
+		BloomSize: 10000000,
+		BloomFP:   0.01,
+		HotSize:   100000,
+		VisitTTL:  24 * time.Hour,
+		HTTPCache: false,
+		HTTPTTL:   30 * 24 * time.Hour,
+		Path:      "./store",
 	},
-	Result: struct{ Path string }{
-		Path: "./result",
+	Result: struct {
+		Path          string
+		Filename      string
+		Columns       []string
+		Rotate        string
+		MaxSize       int64
+		Compress      bool
+		FlushSize     int
+		FlushInterval time.Duration
+	}{
+		Path:          "./result",
+		Filename:      "{date}_domains.csv",
+		Columns:       []string{"domain", "status", "score"},
+		Rotate:        "date",
+		MaxSize:       100 * 1024 * 1024, // 100 MB
+		Compress:      false,
+		FlushSize:     100,
+		FlushInterval: 10 * time.Second,
+	},
+	Score: ScoreSetting{
+		Words:   "",
+		TLDs:    map[string]float64{},
+		Weights: map[string]float64{},
+	},
+	Watch: WatchSetting{
+		Enabled:   false,
+		Window:    30 * 24 * time.Hour,
+		Interval:  time.Minute,
+		NearEvery: 24 * time.Hour,
+		DropEvery: time.Hour,
+	},
+	Robots: RobotsSetting{
+		Enabled:  true,
+		Ignore:   []string{},
+		TTL:      24 * time.Hour,
+		MaxDelay: time.Minute,
+	},
+	Sitemap: SitemapSetting{
+		Discover: false,
+		Since:    0,
+		MaxURLs:  100000,
+	},
+	Politeness: PolitenessSetting{
+		Delay:    time.Second,
+		MaxConns: 1,
+		Rate:     0,
+		Interval: 0,
+		Hosts:    []HostSetting{},
+	},
+	Budget: BudgetSetting{},
+	Priority: PrioritySetting{
+		Enabled:  true,
+		Weights:  map[string]float64{},
+		Keywords: []string{},
+	},
+	Trap: TrapSetting{
+		Enabled:    true,
+		MaxLength:  1024,
+		MaxRepeats: 3,
+		MaxPages:   1000,
+		Patience:   100,
+		Distance:   3,
+	},
+	DeadLinks: DeadLinkSetting{
+		Enabled: false,
+		Timeout: 10 * time.Second,
+		TTL:     time.Hour,
+	},
+	Classify: ClassifySetting{
+		Enabled:    false,
+		Signatures: []string{},
+		Timeout:    15 * time.Second,
+	},
+	Retry: RetrySetting{
+		Attempts:   2,
+		Statuses:   []int{429, 500, 502, 503, 504},
+		Backoff:    time.Second,
+		MaxWait:    30 * time.Second,
+		MaxBackoff: 10 * time.Minute,
+	},
+	Proxy: ProxySetting{
+		File:      "",
+		Strategy:  "round_robin",
+		MaxErrors: 3,
+		Evict:     5 * time.Minute,
+		Reload:    time.Minute,
+	},
+	Request: RequestSetting{
+		Headers:     map[string]string{},
+		Cookies:     false,
+		CookiesFile: "",
+		Hosts:       []RequestHostSetting{},
+	},
+	Guard: GuardSetting{
+		Enabled: true,
+		Allow:   []string{},
 	},
 	Parralle: core,
 	Timeout:  1 * time.Minute,
@@ -82,21 +204,186 @@ type Setting struct {
 		MaxBodySize int64
 		UserAgents  []string
 		Proxies     []string
+		StripParams []string // query params removed from urls, e.g. utm_*
+		Extract     []int    // statuses of pages whose domains are extracted
 	}
 	Log struct {
 		Rotate int // format: 30d
 		Path   string
 	}
 	Store struct {
-		TTL  time.Duration
-		Path string
+		Driver    string // badger, memory, bolt or sqlite
+		Size      int    // max entries of the memory driver
+		TTL       time.Duration
+		TTLs      map[string]time.Duration // ttl by status
+		WriteHits bool                     // write cached results
+		Bloom     bool                     // bloom filter in front of the store
+		BloomSize int                      // expected number of domains
+		BloomFP   float64                  // false positive rate
+		HotSize   int                      // records kept in memory
+		VisitTTL  time.Duration            // a visited url is not fetched again within VisitTTL
+		HTTPCache bool                     // conditional requests with stored validators
+		HTTPTTL   time.Duration            // how long the validators of a url are kept
+		Path      string
 	}
 	Result struct {
-		Path string
+		Path          string
+		Filename      string
+		Columns       []string
+		Rotate        string
+		MaxSize       int64
+		Compress      bool
+		FlushSize     int
+		FlushInterval time.Duration
 	}
-	Parralle int
-	Timeout  time.Duration
-	TLDs     map[string]bool
+	Score      ScoreSetting
+	Watch      WatchSetting
+	Robots     RobotsSetting
+	Sitemap    SitemapSetting
+	Politeness PolitenessSetting
+	Budget     BudgetSetting
+	Priority   PrioritySetting
+	Trap       TrapSetting
+	DeadLinks  DeadLinkSetting
+	Classify   ClassifySetting
+	Retry      RetrySetting
+	Proxy      ProxySetting
+	Request    RequestSetting
+	Guard      GuardSetting
+	Parralle   int
+	Timeout    time.Duration
+	TLDs       map[string]bool
+}
+
+// ScoreSetting
+type ScoreSetting struct {
+	Words   string             // path to word list
+	TLDs    map[string]float64 // tld preference from 0 to 1
+	Weights map[string]float64 // factor weights
+}
+
+// WatchSetting
+type WatchSetting struct {
+	Enabled   bool
+	Window    time.Duration // watch closely this long before expiry
+	Interval  time.Duration // how often the watchlist is scanned
+	NearEvery time.Duration // re-check rate while expiring
+	DropEvery time.Duration // re-check rate in grace, redemption and pending delete
+}
+
+// RobotsSetting
+type RobotsSetting struct {
+	Enabled  bool
+	Ignore   []string      // hosts we own, subdomains included
+	TTL      time.Duration // how long a robots.txt is cached
+	MaxDelay time.Duration // crawl-delay cap
+}
+
+// SitemapSetting
+type SitemapSetting struct {
+	Discover bool          // read the sitemaps of seed hosts
+	Since    time.Duration // skip pages modified earlier, 0 keeps all
+	MaxURLs  int           // max pages read from sitemaps
+}
+
+// PolitenessSetting: per host politeness.
+type PolitenessSetting struct {
+	Delay    time.Duration // between two requests to a host
+	MaxConns int           // concurrent requests to a host
+	Rate     int           // global budget of Rate requests per Interval, 0 means none
+	Interval time.Duration
+	Hosts    []HostSetting // overrides by host pattern
+}
+
+// HostSetting
+type HostSetting struct {
+	Pattern  string // e.g. *.example.com
+	Delay    time.Duration
+	MaxConns int
+}
+
+// BudgetSetting: a crawl stops once a budget is spent, 0 means no limit.
+type BudgetSetting struct {
+	MaxPages     int
+	MaxHostPages int // pages per host
+	MaxBytes     int64
+	MaxRuntime   time.Duration
+	MaxAvailable int // available domains found
+}
+
+// PrioritySetting: urls likely to yield new domains are crawled first.
+type PrioritySetting struct {
+	Enabled  bool
+	Weights  map[string]float64 // factor weights
+	Keywords []string           // url words of link list pages
+}
+
+// TrapSetting: crawl trap heuristics, 0 disables one.
+type TrapSetting struct {
+	Enabled    bool
+	MaxLength  int // max url length
+	MaxRepeats int // max times a path segment appears in a url
+	MaxPages   int // max pages per url pattern
+	Patience   int // pages in a row without new domains before a pattern is skipped
+	Distance   int // max simhash distance of near duplicate pages
+}
+
+// DeadLinkSetting: only domains of broken outbound links are checked.
+type DeadLinkSetting struct {
+	Enabled bool
+	Timeout time.Duration // of a link probe
+	TTL     time.Duration // how long a probe result is kept by host
+}
+
+// ClassifySetting: homepages of registered domains are classified
+// as parked, for sale, redirect or live.
+type ClassifySetting struct {
+	Enabled    bool
+	Signatures []string      // signature files added to the built-in ones
+	Timeout    time.Duration // of a classification
+}
+
+// RetrySetting: failed fetches and retried statuses.
+type RetrySetting struct {
+	Attempts   int           // retries after the first attempt
+	Statuses   []int         // retried statuses
+	Backoff    time.Duration // first wait, doubled on each retry
+	MaxWait    time.Duration // longer waits are not retried
+	MaxBackoff time.Duration // cap of the backoff of a host answering 429
+}
+
+// ProxySetting: the pool of crawler.proxies and the proxies file.
+type ProxySetting struct {
+	File      string        // one proxy per line
+	Strategy  string        // round_robin, random or sticky
+	MaxErrors int           // errors in a row before a proxy is evicted
+	Evict     time.Duration // how long an evicted proxy is left out
+	Reload    time.Duration // how often the file is checked for changes
+}
+
+// RequestSetting: headers, cookies and credentials of page requests,
+// values may use ${ENV} variables.
+type RequestSetting struct {
+	Headers     map[string]string // sent to every host
+	Cookies     bool              // keep the cookies set by hosts
+	CookiesFile string            // Netscape cookies.txt loaded at start
+	Hosts       []RequestHostSetting
+}
+
+// RequestHostSetting
+type RequestHostSetting struct {
+	Pattern  string // e.g. *.example.com
+	Headers  map[string]string
+	Username string // basic auth
+	Password string
+	Token    string // bearer auth
+}
+
+// GuardSetting: connections to private, loopback, link-local and
+// reserved ips are rejected, configured proxies are allowed.
+type GuardSetting struct {
+	Enabled bool
+	Allow   []string // ip ranges, ips and host patterns allowed anyway
 }
 
 // ParseSetting
@@ -114,18 +401,133 @@ func ParseSetting(fp string) *Setting {
 			MaxBodySize string   `yaml:"max_body_size"`
 			UserAgents  []string `yaml:"user_agents,flow"`
 			Proxies     []string `yaml:"proxies,flow"`
+			StripParams []string `yaml:"strip_params,flow"`
+			Extract     []int    `yaml:"extract_statuses,flow"`
 		} `yaml:"crawler"`
 		Log struct {
 			Rotate int    `yaml:"rotate"` // format: 30d
 			Path   string `yaml:"path"`
 		} `yaml:"log"`
 		Store struct {
-			TTL  string `yaml:"ttl"` // format: 1h, 24h
-			Path string `yaml:"path"`
+			Driver    string            `yaml:"driver"`
+			Size      int               `yaml:"size"`
+			TTL       string            `yaml:"ttl"` // format: 1h, 24h
+			TTLs      map[string]string `yaml:"ttl_by_status"`
+			WriteHits bool              `yaml:"write_hits"`
+			Bloom     *bool             `yaml:"bloom"`
+			BloomSize int               `yaml:"bloom_size"`
+			BloomFP   float64           `yaml:"bloom_fp"`
+			HotSize   int               `yaml:"hot_size"`
+			VisitTTL  string            `yaml:"visit_ttl"`
+			HTTPCache *bool             `yaml:"http_cache"`
+			HTTPTTL   string            `yaml:"http_ttl"`
+			Path      string            `yaml:"path"`
 		} `yaml:"store"`
 		Result struct {
-			Path string `yaml:"path"`
+			Path          string   `yaml:"path"`
+			Filename      string   `yaml:"filename"`
+			Columns       []string `yaml:"columns,flow"`
+			Rotate        string   `yaml:"rotate"` // date, size or none
+			MaxSize       string   `yaml:"max_size"`
+			Compress      bool     `yaml:"compress"`
+			FlushSize     int      `yaml:"flush_size"`
+			FlushInterval string   `yaml:"flush_interval"`
 		} `yaml:"result"`
+		Score struct {
+			Words   string             `yaml:"words"`
+			TLDs    map[string]float64 `yaml:"tlds"`
+			Weights map[string]float64 `yaml:"weights"`
+		} `yaml:"score"`
+		Watch struct {
+			Enabled   bool   `yaml:"enabled"`
+			Window    string `yaml:"window"`
+			Interval  string `yaml:"interval"`
+			NearEvery string `yaml:"near_every"`
+			DropEvery string `yaml:"drop_every"`
+		} `yaml:"watch"`
+		Robots struct {
+			Enabled  *bool    `yaml:"enabled"`
+			Ignore   []string `yaml:"ignore,flow"`
+			TTL      string   `yaml:"ttl"`
+			MaxDelay string   `yaml:"max_delay"`
+		} `yaml:"robots"`
+		Sitemap struct {
+			Discover bool   `yaml:"discover"`
+			Since    string `yaml:"since"`
+			MaxURLs  int    `yaml:"max_urls"`
+		} `yaml:"sitemap"`
+		Politeness struct {
+			Delay     string `yaml:"delay"`
+			MaxConns  int    `yaml:"max_conns"`
+			RateLimit string `yaml:"rate_limit"` // global, format: 100/1s
+			Hosts     []struct {
+				Pattern  string `yaml:"pattern"`
+				Delay    string `yaml:"delay"`
+				MaxConns int    `yaml:"max_conns"`
+			} `yaml:"hosts"`
+		} `yaml:"politeness"`
+		Budget struct {
+			MaxPages     int    `yaml:"max_pages"`
+			MaxHostPages int    `yaml:"max_host_pages"`
+			MaxBytes     string `yaml:"max_bytes"`
+			MaxRuntime   string `yaml:"max_runtime"`
+			MaxAvailable int    `yaml:"max_available"`
+		} `yaml:"budget"`
+		Priority struct {
+			Enabled  *bool              `yaml:"enabled"`
+			Weights  map[string]float64 `yaml:"weights"`
+			Keywords []string           `yaml:"keywords,flow"`
+		} `yaml:"priority"`
+		Trap struct {
+			Enabled    *bool `yaml:"enabled"`
+			MaxLength  int   `yaml:"max_length"`
+			MaxRepeats int   `yaml:"max_repeats"`
+			MaxPages   int   `yaml:"max_pattern_pages"`
+			Patience   int   `yaml:"patience"`
+			Distance   int   `yaml:"distance"`
+		} `yaml:"trap"`
+		DeadLinks struct {
+			Enabled bool   `yaml:"enabled"`
+			Timeout string `yaml:"timeout"`
+			TTL     string `yaml:"ttl"`
+		} `yaml:"dead_links"`
+		Classify struct {
+			Enabled    bool     `yaml:"enabled"`
+			Signatures []string `yaml:"signatures,flow"`
+			Timeout    string   `yaml:"timeout"`
+		} `yaml:"classify"`
+		Retry struct {
+			Attempts   int    `yaml:"attempts"`
+			Statuses   []int  `yaml:"statuses,flow"`
+			Backoff    string `yaml:"backoff"`
+			MaxWait    string `yaml:"max_wait"`
+			MaxBackoff string `yaml:"max_backoff"`
+		} `yaml:"retry"`
+		Proxy struct {
+			File      string `yaml:"file"`
+			Strategy  string `yaml:"strategy"`
+			MaxErrors int    `yaml:"max_errors"`
+			Evict     string `yaml:"evict"`
+			Reload    string `yaml:"reload"`
+		} `yaml:"proxy"`
+		Request struct {
+			Headers     map[string]string `yaml:"headers"`
+			Cookies     bool              `yaml:"cookies"`
+			CookiesFile string            `yaml:"cookies_file"`
+			Hosts       []struct {
+				Pattern string            `yaml:"pattern"`
+				Headers map[string]string `yaml:"headers"`
+				Auth    struct {
+					Username string `yaml:"username"`
+					Password string `yaml:"password"`
+					Token    string `yaml:"token"`
+				} `yaml:"auth"`
+			} `yaml:"hosts"`
+		} `yaml:"request"`
+		Guard struct {
+			Enabled *bool    `yaml:"enabled"`
+			Allow   []string `yaml:"allow"`
+		} `yaml:"guard"`
 		Parralle int      `yaml:"parralle"`
 		Timeout  string   `yaml:"timeout"`
 		TLDs     []string `yaml:"tlds,flow"`
@@ -136,6 +538,30 @@ func ParseSetting(fp string) *Setting {
 	}
 
 	rate, interval := parseRateLimit(s.Crawler.RateLimit)
+	globalRate, globalInterval := parseGlobalRate(s.Politeness.RateLimit)
+
+	requestHosts := make([]RequestHostSetting, 0, len(s.Request.Hosts))
+	for _, h := range s.Request.Hosts {
+		requestHosts = append(requestHosts, RequestHostSetting{
+			Pattern:  h.Pattern,
+			Headers:  expandHeaders(h.Headers),
+			Username: expandEnv(h.Auth.Username),
+			Password: expandEnv(h.Auth.Password),
+			Token:    expandEnv(h.Auth.Token),
+		})
+	}
+
+	hosts := make([]HostSetting, 0, len(s.Politeness.Hosts))
+	for _, h := range s.Politeness.Hosts {
+		if h.Pattern == "" {
+			continue
+		}
+		hosts = append(hosts, HostSetting{
+			Pattern:  h.Pattern,
+			Delay:    parseDuration(h.Delay, 0),
+			MaxConns: h.MaxConns,
+		})
+	}
 
 	return &Setting{
 		Crawler: struct {
@@ -148,6 +574,8 @@ func ParseSetting(fp string) *Setting {
 			MaxBodySize int64
 			UserAgents  []string
 			Proxies     []string
+			StripParams []string
+			Extract     []int
 		}{
 			MaxDepth: s.Crawler.MaxDepth,
 			Filter:   s.Crawler.Filter,
@@ -161,6 +589,8 @@ func ParseSetting(fp string) *Setting {
 			MaxBodySize: parseBodySize(s.Crawler.MaxBodySize),
 			UserAgents:  s.Crawler.UserAgents,
 			Proxies:     s.Crawler.Proxies,
+			StripParams: parseStripParams(s.Crawler.StripParams),
+			Extract:     parseExtract(s.Crawler.Extract),
 		},
 		Log: struct {
 			Rotate int
@@ -170,14 +600,137 @@ func ParseSetting(fp string) *Setting {
 			Path:   s.Log.Path,
 		},
 		Store: struct {
-			TTL  time.Duration
-			Path string
+			Driver    string
+			Size      int
+			TTL       time.Duration
+			TTLs      map[string]time.Duration
+			WriteHits bool
+			Bloom     bool
+			BloomSize int
+			BloomFP   float64
+			HotSize   int
+			VisitTTL  time.Duration
+			HTTPCache bool
+			HTTPTTL   time.Duration
+			Path      string
+		}{
+			Driver:    parseDriver(s.Store.Driver),
+			Size:      parseStoreSize(s.Store.Size),
+			TTL:       parseTTL(s.Store.TTL),
+			TTLs:      parseTTLs(s.Store.TTLs),
+			WriteHits: s.Store.WriteHits,
+			Bloom:     parseBool(s.Store.Bloom, defaultSetting.Store.Bloom),
+			BloomSize: parseBloomSize(s.Store.BloomSize),
+			BloomFP:   parseBloomFP(s.Store.BloomFP),
+			HotSize:   parseHotSize(s.Store.HotSize),
+			VisitTTL:  parseDuration(s.Store.VisitTTL, defaultSetting.Store.VisitTTL),
+			HTTPCache: parseBool(s.Store.HTTPCache, defaultSetting.Store.HTTPCache),
+			HTTPTTL:   parseDuration(s.Store.HTTPTTL, defaultSetting.Store.HTTPTTL),
+			Path:      s.Store.Path,
+		},
+		Result: struct {
+			Path          string
+			Filename      string
+			Columns       []string
+			Rotate        string
+			MaxSize       int64
+			Compress      bool
+			FlushSize     int
+			FlushInterval time.Duration
 		}{
-			TTL:  parseTTL(s.Store.TTL),
-			Path: s.Store.Path,
+			Path:          s.Result.Path,
+			Filename:      parseFilename(s.Result.Filename),
+			Columns:       parseColumns(s.Result.Columns),
+			Rotate:        parseRotate(s.Result.Rotate),
+			MaxSize:       parseMaxSize(s.Result.MaxSize),
+			Compress:      s.Result.Compress,
+			FlushSize:     parseFlushSize(s.Result.FlushSize),
+			FlushInterval: parseFlushInterval(s.Result.FlushInterval),
+		},
+		Score: ScoreSetting{
+			Words:   s.Score.Words,
+			TLDs:    s.Score.TLDs,
+			Weights: s.Score.Weights,
+		},
+		Watch: WatchSetting{
+			Enabled:   s.Watch.Enabled,
+			Window:    parseDuration(s.Watch.Window, defaultSetting.Watch.Window),
+			Interval:  parseDuration(s.Watch.Interval, defaultSetting.Watch.Interval),
+			NearEvery: parseDuration(s.Watch.NearEvery, defaultSetting.Watch.NearEvery),
+			DropEvery: parseDuration(s.Watch.DropEvery, defaultSetting.Watch.DropEvery),
+		},
+		Robots: RobotsSetting{
+			Enabled:  parseBool(s.Robots.Enabled, defaultSetting.Robots.Enabled),
+			Ignore:   s.Robots.Ignore,
+			TTL:      parseDuration(s.Robots.TTL, defaultSetting.Robots.TTL),
+			MaxDelay: parseDuration(s.Robots.MaxDelay, defaultSetting.Robots.MaxDelay),
+		},
+		Sitemap: SitemapSetting{
+			Discover: s.Sitemap.Discover,
+			Since:    parseDuration(s.Sitemap.Since, defaultSetting.Sitemap.Since),
+			MaxURLs:  parseMaxURLs(s.Sitemap.MaxURLs),
 		},
-		Result: struct{ Path string }{
-			Path: s.Result.Path,
+		Politeness: PolitenessSetting{
+			// crawler.rate_limit was applied per host
+			Delay:    parseDuration(s.Politeness.Delay, perRequest(rate, interval)),
+			MaxConns: parseMaxConns(s.Politeness.MaxConns),
+			Rate:     globalRate,
+			Interval: globalInterval,
+			Hosts:    hosts,
+		},
+		Budget: BudgetSetting{
+			MaxPages:     s.Budget.MaxPages,
+			MaxHostPages: s.Budget.MaxHostPages,
+			MaxBytes:     hbyte.Parse(s.Budget.MaxBytes),
+			MaxRuntime:   parseDuration(s.Budget.MaxRuntime, 0),
+			MaxAvailable: s.Budget.MaxAvailable,
+		},
+		Priority: PrioritySetting{
+			Enabled:  parseBool(s.Priority.Enabled, defaultSetting.Priority.Enabled),
+			Weights:  s.Priority.Weights,
+			Keywords: s.Priority.Keywords,
+		},
+		Trap: TrapSetting{
+			Enabled:    parseBool(s.Trap.Enabled, defaultSetting.Trap.Enabled),
+			MaxLength:  parseLimit(s.Trap.MaxLength, defaultSetting.Trap.MaxLength),
+			MaxRepeats: parseLimit(s.Trap.MaxRepeats, defaultSetting.Trap.MaxRepeats),
+			MaxPages:   parseLimit(s.Trap.MaxPages, defaultSetting.Trap.MaxPages),
+			Patience:   parseLimit(s.Trap.Patience, defaultSetting.Trap.Patience),
+			Distance:   parseLimit(s.Trap.Distance, defaultSetting.Trap.Distance),
+		},
+		DeadLinks: DeadLinkSetting{
+			Enabled: s.DeadLinks.Enabled,
+			Timeout: parseDuration(s.DeadLinks.Timeout, defaultSetting.DeadLinks.Timeout),
+			TTL:     parseDuration(s.DeadLinks.TTL, defaultSetting.DeadLinks.TTL),
+		},
+		Classify: ClassifySetting{
+			Enabled:    s.Classify.Enabled,
+			Signatures: s.Classify.Signatures,
+			Timeout:    parseDuration(s.Classify.Timeout, defaultSetting.Classify.Timeout),
+		},
+		Retry: RetrySetting{
+			Attempts:   parseLimit(s.Retry.Attempts, defaultSetting.Retry.Attempts),
+			Statuses:   parseRetryStatuses(s.Retry.Statuses),
+			Backoff:    parseDuration(s.Retry.Backoff, defaultSetting.Retry.Backoff),
+			MaxWait:    parseDuration(s.Retry.MaxWait, defaultSetting.Retry.MaxWait),
+			MaxBackoff: parseDuration(s.Retry.MaxBackoff, defaultSetting.Retry.MaxBackoff),
+		},
+		Proxy: ProxySetting{
+			File:      s.Proxy.File,
+			Strategy:  parseStrategy(s.Proxy.Strategy),
+			MaxErrors: parseLimit(s.Proxy.MaxErrors, defaultSetting.Proxy.MaxErrors),
+			Evict:     parseDuration(s.Proxy.Evict, defaultSetting.Proxy.Evict),
+			Reload:    parseDuration(s.Proxy.Reload, defaultSetting.Proxy.Reload),
+		},
+		Request: RequestSetting{
+			Headers:     expandHeaders(s.Request.Headers),
+			Cookies:     s.Request.Cookies,
+			CookiesFile: s.Request.CookiesFile,
+			Hosts:       requestHosts,
+		},
+		Guard: GuardSetting{
+			Enabled: parseBool(s.Guard.Enabled, defaultSetting.Guard.Enabled),
+			Allow:   s.Guard.Allow,
 		},
 		Parralle: s.Parralle,
 		Timeout:  parseTimeout(s.Timeout),
@@ -214,6 +767,30 @@ func parseRateLimit(s string) (int, time.Duration) {
 	return rate, interval
 }
 
+// parseGlobalRate: no global budget by default.
+func parseGlobalRate(s string) (int, time.Duration) {
+	if s == "" {
+		return 0, 0
+	}
+	return parseRateLimit(s)
+}
+
+// perRequest
+func perRequest(rate int, interval time.Duration) time.Duration {
+	if rate <= 0 {
+		return defaultSetting.Politeness.Delay
+	}
+	return interval / time.Duration(rate)
+}
+
+// parseMaxConns
+func parseMaxConns(n int) int {
+	if n <= 0 {
+		return defaultSetting.Politeness.MaxConns
+	}
+	return n
+}
+
 // parseTLDs
 func parseTLDs(list []string) map[string]bool {
 	m := map[string]bool{}
@@ -241,6 +818,139 @@ func parseTTL(s string) time.Duration {
 	return d
 }
 
+// parseDriver
+func parseDriver(s string) string {
+	if s == "" {
+		return defaultSetting.Store.Driver
+	}
+	return s
+}
+
+// parseStoreSize
+func parseStoreSize(n int) int {
+	if n <= 0 {
+		return defaultSetting.Store.Size
+	}
+	return n
+}
+
+// parseBool
+func parseBool(b *bool, def bool) bool {
+	if b == nil {
+		return def
+	}
+	return *b
+}
+
+// parseBloomSize
+func parseBloomSize(n int) int {
+	if n <= 0 {
+		return defaultSetting.Store.BloomSize
+	}
+	return n
+}
+
+// parseBloomFP
+func parseBloomFP(fp float64) float64 {
+	if fp <= 0 || fp >= 1 {
+		return defaultSetting.Store.BloomFP
+	}
+	return fp
+}
+
+// parseHotSize: a negative size disables the hot cache.
+func parseHotSize(n int) int {
+	switch {
+	case n < 0:
+		return 0
+	case n == 0:
+		return defaultSetting.Store.HotSize
+	default:
+		return n
+	}
+}
+
+// parseLimit: 0 means def, a negative limit disables it.
+func parseLimit(n, def int) int {
+	switch {
+	case n < 0:
+		return 0
+	case n == 0:
+		return def
+	default:
+		return n
+	}
+}
+
+// parseTTLs
+func parseTTLs(m map[string]string) map[string]time.Duration {
+	ttls := map[string]time.Duration{}
+	for status, s := range m {
+		if d, err := time.ParseDuration(s); err == nil {
+			ttls[status] = d
+		}
+	}
+	return ttls
+}
+
+// parseExtract
+func parseExtract(list []int) []int {
+	if len(list) == 0 {
+		return defaultSetting.Crawler.Extract
+	}
+	return list
+}
+
+// parseRetryStatuses
+func parseRetryStatuses(list []int) []int {
+	if len(list) == 0 {
+		return defaultSetting.Retry.Statuses
+	}
+	return list
+}
+
+// envVar: ${NAME} references in config values.
+var envVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${NAME} with the value of the environment variable NAME.
+func expandEnv(s string) string {
+	return envVar.ReplaceAllStringFunc(s, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// expandHeaders
+func expandHeaders(m map[string]string) map[string]string {
+	headers := make(map[string]string, len(m))
+	for k, v := range m {
+		headers[k] = expandEnv(v)
+	}
+	return headers
+}
+
+// parseStrategy
+func parseStrategy(s string) string {
+	switch s {
+	case "round_robin", "random", "sticky":
+		return s
+	default:
+		return defaultSetting.Proxy.Strategy
+	}
+}
+
+// parseStripParams: the defaults are always stripped.
+func parseStripParams(list []string) []string {
+	return append(append([]string{}, defaultSetting.Crawler.StripParams...), list...)
+}
+
+// parseMaxURLs
+func parseMaxURLs(n int) int {
+	if n <= 0 {
+		return defaultSetting.Sitemap.MaxURLs
+	}
+	return n
+}
+
 // parseBodySize
 func parseBodySize(s string) int64 {
 	size := hbyte.Parse(s)
@@ -249,3 +959,66 @@ func parseBodySize(s string) int64 {
 	}
 	return size
 }
+
+// parseFlushSize
+func parseFlushSize(n int) int {
+	if n <= 0 {
+		return defaultSetting.Result.FlushSize
+	}
+	return n
+}
+
+// parseFlushInterval
+func parseFlushInterval(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return defaultSetting.Result.FlushInterval
+	}
+	return d
+}
+
+// parseFilename
+func parseFilename(s string) string {
+	if s == "" {
+		return defaultSetting.Result.Filename
+	}
+	return s
+}
+
+// parseColumns
+func parseColumns(list []string) []string {
+	if len(list) == 0 {
+		return defaultSetting.Result.Columns
+	}
+	return list
+}
+
+// parseRotate
+func parseRotate(s string) string {
+	switch s {
+	case "":
+		return defaultSetting.Result.Rotate
+	case "none":
+		return ""
+	default:
+		return s
+	}
+}
+
+// parseMaxSize
+func parseMaxSize(s string) int64 {
+	size := hbyte.Parse(s)
+	if size == 0 {
+		return defaultSetting.Result.MaxSize
+	}
+	return size
+}
+
+// parseDuration
+func parseDuration(s string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
@@ -1,7 +1,83 @@
 package spider
 
-// Storage
+import (
+	"errors"
+	"time"
+)
+
+// Errs
+var (
+	ErrNotFound = errors.New("record not found")
+)
+
+// Storage: a domain is checked once per ttl using a reserve,
+// commit or release flow.
+//
+//	rec, ok, err := store.Reserve(name, sighting)
+//	if ok {
+//		// check name then
+//		store.Commit(result) // or store.Release(name, err) on failure
+//	}
 type Storage interface {
-	HasChecked(name string) bool
+	Get(name string) (*Record, error)
+	Reserve(name string, r Referrers) (*Record, bool, error)
+	Commit(rec *Record) error
+	Release(name string, cause error) error
 	Close() error
 }
+
+// Watchlist
+type Watchlist interface {
+	Watch(w *Watch) error
+	Unwatch(name string) error
+	Due(t time.Time) ([]*Watch, error)
+}
+
+// Visits: urls fetched within a ttl, across runs.
+type Visits interface {
+	// Visit marks link as visited, seen is true if it already was.
+	Visit(link string) (seen bool, err error)
+}
+
+// Validators: http cache validators of a fetched url, with the links
+// found on it to follow when it is not modified.
+type Validators struct {
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Links        []string `json:"links,omitempty"`
+}
+
+// Conditional: validators of fetched urls, across runs.
+type Conditional interface {
+	// Validators of link, ErrNotFound if none were kept.
+	Validators(link string) (Validators, error)
+	SetValidators(link string, v Validators) error
+}
+
+// check backends
+const (
+	BackendWHOIS = "whois"
+)
+
+// Record: last check result of a domain.
+type Record struct {
+	Name       string    `json:"name"` // root domain
+	Status     string    `json:"status"`
+	Backend    string    `json:"backend"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Err        string    `json:"error,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Referrers  Referrers `json:"referrers"`
+	Site       string    `json:"site,omitempty"` // homepage class of a registered domain
+	SiteDetail string    `json:"site_detail,omitempty"`
+}
+
+// Checked reports whether the record holds a successful check.
+func (r *Record) Checked() bool {
+	return r.Status != "" && r.Err == ""
+}
+
+// Expired
+func (r *Record) Expired(t time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !t.Before(r.ExpiresAt)
+}
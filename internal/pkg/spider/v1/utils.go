@@ -2,6 +2,7 @@ package spider
 
 import (
 	"bytes"
+	"net/url"
 	"regexp"
 	"strings"
 
@@ -14,7 +15,8 @@ var (
 	domainRegexp = regexp.MustCompile(`(([[:alnum:]]-?)?([[:alnum:]]-?)+\.)+[[:alpha:]]{2,4}`)
 )
 
-// FindDomains
+// FindDomains: extract unique domains from a page body,
+// anchor texts linking to a domain are kept as referrers.
 func FindDomains(body []byte) (domains []Domain) {
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
@@ -23,15 +25,97 @@ func FindDomains(body []byte) (domains []Domain) {
 
 	var s = UnescapeHTML.Replace(doc.Text())
 
+	var seen = map[string]int{}
 	for _, domain := range domainRegexp.FindAllString(s, -1) {
 		name, tld, ok := splitDomain(domain)
-		if ok {
+		if !ok {
+			continue
+		}
+
+		root := name + "." + tld
+		if _, found := seen[root]; found {
+			continue
+		}
+		seen[root] = len(domains)
+
+		domains = append(domains, Domain{
+			Name: name,
+			TLD:  tld,
+		})
+	}
+
+	// anchor texts
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		u, err := url.Parse(href)
+		if err != nil || u.Hostname() == "" {
+			return
+		}
+
+		name, tld, ok := splitDomain(u.Hostname())
+		if !ok {
+			return
+		}
+
+		i, found := seen[name+"."+tld]
+		if !found {
+			return
+		}
+
+		text := strings.Join(strings.Fields(a.Text()), " ")
+		if text == "" {
+			return
+		}
+
+		domains[i].Referrers.Merge(Referrers{Anchors: []string{text}})
+	})
+
+	return
+}
+
+// FindLinks: extract unique domains linked from a page body, each with
+// the first link to it and the anchor texts of its links.
+func FindLinks(body []byte) (domains []Domain) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	var seen = map[string]int{}
+	doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		u, err := url.Parse(strings.TrimSpace(href))
+		if err != nil || u.Hostname() == "" {
+			return
+		}
+
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return
+		}
+
+		name, tld, ok := splitDomain(u.Hostname())
+		if !ok {
+			return
+		}
+
+		i, found := seen[name+"."+tld]
+		if !found {
+			i = len(domains)
+			seen[name+"."+tld] = i
 			domains = append(domains, Domain{
 				Name: name,
 				TLD:  tld,
+				Link: u.String(),
 			})
 		}
-	}
+
+		text := strings.Join(strings.Fields(a.Text()), " ")
+		if text == "" {
+			return
+		}
+
+		domains[i].Referrers.Merge(Referrers{Anchors: []string{text}})
+	})
 
 	return
 }
@@ -0,0 +1,15 @@
+package spider
+
+import "time"
+
+// Watch: a registered domain monitored until it drops.
+type Watch struct {
+	Name      string    `json:"name"`
+	TLD       string    `json:"tld"`
+	URL       string    `json:"url"`
+	Status    string    `json:"status"`
+	Phase     string    `json:"phase"`
+	Expiry    time.Time `json:"expiry"`
+	CheckedAt time.Time `json:"checked_at"`
+	NextCheck time.Time `json:"next_check"`
+}
@@ -3,4 +3,6 @@ package spider
 // Writer
 type Writer interface {
 	Write(*Domain) error
+	Flush() error
+	Close() error
 }
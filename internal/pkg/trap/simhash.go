@@ -0,0 +1,98 @@
+package trap
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Simhash: 64 bit fingerprint of the text of an html page, near duplicate
+// pages have fingerprints a few bits apart. it returns 0 for a page without text.
+func Simhash(body []byte) uint64 {
+	var (
+		v [64]int
+		n int
+	)
+
+	for _, w := range words(text(body)) {
+		h := fnv.New64a()
+		h.Write([]byte(w))
+		sum := h.Sum64()
+
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				v[i]++
+			} else {
+				v[i]--
+			}
+		}
+		n++
+	}
+
+	if n == 0 {
+		return 0
+	}
+
+	var fp uint64
+	for i := 0; i < 64; i++ {
+		if v[i] > 0 {
+			fp |= 1 << uint(i)
+		}
+	}
+	return fp
+}
+
+// Distance: number of bits a and b differ by.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// text of an html page, tags, scripts and styles removed.
+func text(body []byte) string {
+	var (
+		b    strings.Builder
+		tag  bool
+		skip string // closing tag of a script or style
+	)
+
+	s := string(body)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case skip != "":
+			if c == '<' && hasTag(s[i:], skip) {
+				skip = ""
+				tag = true
+			}
+		case tag:
+			if c == '>' {
+				tag = false
+				b.WriteByte(' ')
+			}
+		case c == '<':
+			tag = true
+			if hasTag(s[i:], "<script") {
+				skip = "</script"
+			} else if hasTag(s[i:], "<style") {
+				skip = "</style"
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// hasTag reports whether s starts with tag, ignoring case.
+func hasTag(s, tag string) bool {
+	return len(s) >= len(tag) && strings.EqualFold(s[:len(tag)], tag)
+}
+
+// words: lowercase words of s.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
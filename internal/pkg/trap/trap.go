@@ -0,0 +1,224 @@
+package trap
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+)
+
+const (
+	maxPatterns     = 100000 // url patterns tracked
+	maxFingerprints = 16     // recent page fingerprints kept per pattern
+)
+
+var (
+	digits = regexp.MustCompile(`[0-9]+`)
+	// ids: hex, base64 or uuid like tokens, e.g. session ids
+	ids = regexp.MustCompile(`^[0-9a-zA-Z_-]{16,}$`)
+)
+
+// trap reasons
+const (
+	ReasonLength  = "length"
+	ReasonRepeats = "repeats"
+	ReasonPattern = "pattern"
+)
+
+// Config: 0 disables a heuristic.
+type Config struct {
+	MaxLength  int // max url length
+	MaxRepeats int // max times a path segment appears in a url
+	MaxPages   int // max pages fetched per url pattern
+	Patience   int // pages in a row without new domains before a pattern is closed
+	Distance   int // max simhash distance of near duplicate pages
+}
+
+// Stats: urls refused by reason and near duplicate pages.
+type Stats struct {
+	Length     uint64
+	Repeats    uint64
+	Pattern    uint64
+	Duplicates uint64
+	Closed     uint64 // patterns closed
+}
+
+// pattern
+type pattern struct {
+	pages  int
+	barren int // pages in a row without new domains
+	closed bool
+	prints []uint64
+}
+
+// Detector finds crawl traps: infinite calendars, faceted search and
+// session urls. urls are grouped by pattern, a pattern is closed once its
+// pages stop yielding new domains or repeat each other.
+type Detector struct {
+	mu       *sync.Mutex
+	conf     Config
+	patterns *lru.Cache[string, *pattern]
+	stats    Stats
+}
+
+// NewDetector
+func NewDetector(conf Config) *Detector {
+	return &Detector{
+		mu:       &sync.Mutex{},
+		conf:     conf,
+		patterns: lru.New[string, *pattern](maxPatterns),
+	}
+}
+
+// Allow reports whether u should be crawled, else why not.
+func (d *Detector) Allow(u *url.URL) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.conf.MaxLength > 0 && len(u.String()) > d.conf.MaxLength {
+		d.stats.Length++
+		return ReasonLength, false
+	}
+
+	if d.conf.MaxRepeats > 0 && repeats(u.Path) > d.conf.MaxRepeats {
+		d.stats.Repeats++
+		return ReasonRepeats, false
+	}
+
+	if p, found := d.patterns.Get(Pattern(u)); found && p.closed {
+		d.stats.Pattern++
+		return ReasonPattern, false
+	}
+
+	return "", true
+}
+
+// Page records a fetched page of u and how many new domains it yielded,
+// it returns true if the pattern of u was closed by this page.
+func (d *Detector) Page(u *url.URL, body []byte, fresh int) bool {
+	key := Pattern(u)
+
+	var fp uint64
+	if d.conf.Distance > 0 {
+		fp = Simhash(body)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, found := d.patterns.Get(key)
+	if !found {
+		p = &pattern{}
+		d.patterns.Add(key, p)
+	}
+
+	if p.closed {
+		return false
+	}
+
+	p.pages++
+
+	dup := fp != 0 && p.duplicate(fp, d.conf.Distance)
+	if dup {
+		d.stats.Duplicates++
+	} else if fp != 0 {
+		p.remember(fp)
+	}
+
+	if fresh > 0 && !dup {
+		p.barren = 0
+	} else {
+		p.barren++
+	}
+
+	if (d.conf.Patience > 0 && p.barren >= d.conf.Patience) ||
+		(d.conf.MaxPages > 0 && p.pages >= d.conf.MaxPages) {
+		p.closed = true
+		p.prints = nil
+		d.stats.Closed++
+		return true
+	}
+
+	return false
+}
+
+// Stats
+func (d *Detector) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+// duplicate reports whether fp is near a recent page of the pattern.
+func (p *pattern) duplicate(fp uint64, distance int) bool {
+	for _, o := range p.prints {
+		if Distance(fp, o) <= distance {
+			return true
+		}
+	}
+	return false
+}
+
+// remember fp, the oldest fingerprint is dropped.
+func (p *pattern) remember(fp uint64) {
+	if len(p.prints) >= maxFingerprints {
+		copy(p.prints, p.prints[1:])
+		p.prints = p.prints[:len(p.prints)-1]
+	}
+	p.prints = append(p.prints, fp)
+}
+
+// Pattern of a url: lowercase host, path with numbers and ids replaced and
+// query keys without values, e.g. example.com/cal/{n}/{n}?view.
+func Pattern(u *url.URL) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(u.Hostname()))
+
+	for _, seg := range strings.Split(strings.Trim(u.EscapedPath(), "/"), "/") {
+		b.WriteByte('/')
+
+		// path params, e.g. ;jsessionid=...
+		if i := strings.IndexByte(seg, ';'); i >= 0 {
+			seg = seg[:i] + ";{id}"
+		}
+
+		if ids.MatchString(seg) && digits.MatchString(seg) {
+			b.WriteString("{id}")
+			continue
+		}
+		b.WriteString(digits.ReplaceAllString(strings.ToLower(seg), "{n}"))
+	}
+
+	if q := u.Query(); len(q) > 0 {
+		keys := make([]string, 0, len(q))
+		for k := range q {
+			keys = append(keys, strings.ToLower(k))
+		}
+		sort.Strings(keys)
+		b.WriteString("?" + strings.Join(keys, "&"))
+	}
+
+	return b.String()
+}
+
+// repeats: how many times the most repeated segment of p appears.
+func repeats(p string) int {
+	var (
+		count = map[string]int{}
+		max   int
+	)
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" {
+			continue
+		}
+		count[seg]++
+		if count[seg] > max {
+			max = count[seg]
+		}
+	}
+	return max
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"time"
+
+	//
+	"github.com/twiny/carbon"
+)
+
+// badgerKV
+type badgerKV struct {
+	db *carbon.Cache
+}
+
+// newBadgerKV
+func newBadgerKV(dir string) (*badgerKV, error) {
+	db, err := carbon.NewCache(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &badgerKV{db: db}, nil
+}
+
+// get: val is only valid in the badger transaction, it is copied.
+func (b *badgerKV) get(key string) ([]byte, error) {
+	val, err := b.db.Get(key)
+	if err == carbon.ErrNoRow {
+		return nil, errNoRow
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), val...), nil
+}
+
+// set
+func (b *badgerKV) set(key string, val []byte, ttl time.Duration) error {
+	return b.db.Set(key, val, ttl)
+}
+
+// del
+func (b *badgerKV) del(key string) error {
+	return b.db.Del(key)
+}
+
+// each
+func (b *badgerKV) each(prefix string, fn func(key string, val []byte) error) error {
+	// collect copies first, values are only valid in the
+	// transaction and fn may write to the store
+	var pairs []pair
+	if err := b.db.ForEach(prefix, func(key string, val []byte) error {
+		pairs = append(pairs, pair{key, append([]byte(nil), val...)})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return apply(pairs, fn)
+}
+
+// compact: badger drops expired keys on its own.
+func (b *badgerKV) compact() (int, error) {
+	return 0, nil
+}
+
+// close
+func (b *badgerKV) close() error {
+	b.db.Close()
+	return nil
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"bytes"
+	"time"
+
+	//
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucket
+var boltBucket = []byte("spidy")
+
+// boltKV: a single file store, expiry is kept in each value.
+type boltKV struct {
+	db *bolt.DB
+}
+
+// newBoltKV
+func newBoltKV(fp string) (*boltKV, error) {
+	db, err := bolt.Open(fp, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltKV{db: db}, nil
+}
+
+// get
+func (b *boltKV) get(key string) ([]byte, error) {
+	var val []byte
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		v, ok := open(tx.Bucket(boltBucket).Get([]byte(key)))
+		if !ok {
+			return errNoRow
+		}
+		val = v
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// set
+func (b *boltKV) set(key string, val []byte, ttl time.Duration) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), envelope(val, ttl))
+	})
+}
+
+// del
+func (b *boltKV) del(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+// each
+func (b *boltKV) each(prefix string, fn func(key string, val []byte) error) error {
+	// collect first, fn may write to the store
+	var pairs []pair
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		seek := []byte(prefix)
+		for k, v := c.Seek(seek); k != nil && bytes.HasPrefix(k, seek); k, v = c.Next() {
+			if val, ok := open(v); ok {
+				pairs = append(pairs, pair{string(k), val})
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return apply(pairs, fn)
+}
+
+// compact
+func (b *boltKV) compact() (int, error) {
+	var n int
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+
+		// deleting while iterating skips keys
+		var keys [][]byte
+		if err := bucket.ForEach(func(k, v []byte) error {
+			if _, ok := open(v); !ok {
+				keys = append(keys, append([]byte{}, k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
+
+// close
+func (b *boltKV) close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,13 @@
+package cache_test
+
+import (
+	"testing"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/cache"
+)
+
+// TestBolt
+func TestBolt(t *testing.T) {
+	testDriver(t, cache.DriverBolt)
+}
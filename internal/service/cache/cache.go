@@ -1,48 +1,666 @@
 package cache
 
 import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	//
+	"github.com/twiny/spidy/v2/internal/pkg/bloom"
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+)
 
-	//
-	"github.com/twiny/carbon"
+// drivers
+const (
+	DriverBadger = "badger"
+	DriverMemory = "memory"
+	DriverBolt   = "bolt"
+	DriverSQLite = "sqlite"
+)
+
+// Config
+type Config struct {
+	Driver string
+	Path   string // store directory
+	Size   int    // max entries of the memory driver
+	TTL    time.Duration
+	TTLs   map[string]time.Duration // ttl by status
+
+	VisitTTL time.Duration // how long a visited url is kept
+	HTTPTTL  time.Duration // how long the validators of a url are kept
+
+	Bloom     bool    // keep a bloom filter of stored domains
+	BloomSize int     // expected number of domains
+	BloomFP   float64 // bloom false positive rate
+	HotSize   int     // records kept in memory, 0 disables it
+}
+
+// Stats: lookup counters of the store.
+type Stats struct {
+	Lookups     uint64 // record lookups
+	HotHits     uint64 // found in memory
+	BloomSkips  uint64 // definite misses answered by the bloom filter
+	StoreHits   uint64 // found in the store, expired records included
+	StoreMisses uint64 // not in the store, bloom false positives included
+}
+
+// key prefixes
+const (
+	watchPrefix = "watch/"
+	urlPrefix   = "url/"
+	httpPrefix  = "http/"
+)
+
+// bloomFile is kept in the store directory between runs.
+const bloomFile = "bloom.bin"
+
+// leaseTTL: a reservation not committed or released
+// within leaseTTL is given to the next worker.
+const leaseTTL = 30 * time.Minute
+
+// referrers of known domains are merged in memory and written
+// once maxPending domains are pending, every flushEvery or on Close.
+const (
+	maxPending = 10000
+	flushEvery = time.Minute
 )
 
 // Cache
 type Cache struct {
-	ttl time.Duration
-	db  *carbon.Cache
+	stats    Stats // first for 64-bit atomic alignment
+	mu       *sync.Mutex
+	ttl      time.Duration
+	ttls     map[string]time.Duration // ttl by status
+	visitTTL time.Duration
+	httpTTL  time.Duration
+	reserved map[string]time.Time // reservation leases
+	pending  map[string]spider.Referrers
+	flushed  time.Time     // last write of pending referrers
+	bloom    *bloom.Filter // nil if disabled
+	bloomFP  string        // path of the persisted filter
+	hot      *lru.Cache[string, []byte]
+	db       kv
 }
 
-// NewCache
-func NewCache(ttl time.Duration, dir string) (*Cache, error) {
-	db, err := carbon.NewCache(dir)
+// New opens the store of the configured driver.
+func New(conf Config) (*Cache, error) {
+	db, err := openKV(conf)
 	if err != nil {
 		return nil, err
 	}
-	return &Cache{
-		ttl: ttl,
-		db:  db,
-	}, nil
+
+	c := &Cache{
+		mu:       &sync.Mutex{},
+		ttl:      conf.TTL,
+		ttls:     conf.TTLs,
+		visitTTL: conf.VisitTTL,
+		httpTTL:  conf.HTTPTTL,
+		reserved: map[string]time.Time{},
+		pending:  map[string]spider.Referrers{},
+		flushed:  time.Now(),
+		db:       db,
+	}
+
+	if conf.HotSize > 0 {
+		c.hot = lru.New[string, []byte](conf.HotSize)
+	}
+
+	if conf.Bloom {
+		if conf.Driver != DriverMemory {
+			c.bloomFP = filepath.Join(conf.Path, bloomFile)
+		}
+		if err := c.loadBloom(conf); err != nil {
+			db.close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// loadBloom reads the filter saved by Close or rebuilds it from the store.
+// the file is removed once read so a crash cannot leave a stale filter.
+func (c *Cache) loadBloom(conf Config) error {
+	if c.bloomFP != "" {
+		f, err := os.Open(c.bloomFP)
+		if err == nil {
+			var size int64
+			if info, serr := f.Stat(); serr == nil {
+				size = info.Size()
+			}
+
+			filter, rerr := bloom.Read(bufio.NewReader(f), size)
+			f.Close()
+
+			if err := os.Remove(c.bloomFP); err != nil {
+				return err
+			}
+
+			// a corrupt filter is rebuilt
+			if rerr == nil {
+				c.bloom = filter
+				return nil
+			}
+		}
+	}
+
+	filter := bloom.New(uint64(conf.BloomSize), conf.BloomFP)
+	if err := c.db.each("", func(key string, _ []byte) error {
+		if !strings.Contains(key, "/") {
+			filter.Add(key)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.bloom = filter
+	return nil
+}
+
+// saveBloom
+func (c *Cache) saveBloom() error {
+	if c.bloom == nil || c.bloomFP == "" {
+		return nil
+	}
+
+	tmp := c.bloomFP + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if _, err := c.bloom.WriteTo(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.bloomFP)
+}
+
+// openKV
+func openKV(conf Config) (kv, error) {
+	if conf.Driver != DriverMemory {
+		if err := os.MkdirAll(conf.Path, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	switch conf.Driver {
+	case DriverBadger, "":
+		return newBadgerKV(conf.Path)
+	case DriverMemory:
+		return newMemoryKV(conf.Size), nil
+	case DriverBolt:
+		return newBoltKV(filepath.Join(conf.Path, "spidy.db"))
+	case DriverSQLite:
+		return newSQLiteKV(filepath.Join(conf.Path, "spidy.sqlite"))
+	default:
+		return nil, fmt.Errorf("unknown store driver %q", conf.Driver)
+	}
+}
+
+// Get the record of a domain.
+func (c *Cache) Get(name string) (*spider.Record, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(name, time.Now())
+}
+
+// Reserve merges r into the referrers of name and reserves name for
+// a check. ok is false if name is already checked or reserved.
+// only new domains are written, the referrers of known ones are
+// written in batches.
+func (c *Cache) Reserve(name string, r spider.Referrers) (*spider.Record, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	rec, err := c.get(name, now)
+	switch err {
+	case nil:
+		rec.Referrers.Merge(r)
+
+		p := c.pending[name]
+		p.Merge(r)
+		c.pending[name] = p
+
+		if len(c.pending) >= maxPending || now.Sub(c.flushed) >= flushEvery {
+			if err := c.flush(now); err != nil {
+				return nil, false, err
+			}
+		}
+	case spider.ErrNotFound:
+		rec = &spider.Record{
+			Name:      name,
+			ExpiresAt: now.Add(c.ttl),
+		}
+		rec.Referrers.Merge(r)
+
+		if err := c.set(rec, now); err != nil {
+			return nil, false, err
+		}
+	default:
+		return nil, false, err
+	}
+
+	if rec.Checked() {
+		return rec, false, nil
+	}
+
+	// reserved by another worker
+	if until, found := c.reserved[name]; found && now.Before(until) {
+		return rec, false, nil
+	}
+
+	c.reserved[name] = now.Add(leaseTTL)
+
+	return rec, true, nil
+}
+
+// Commit stores the check result of rec and releases its reservation,
+// referrers already stored are kept.
+func (c *Cache) Commit(rec *spider.Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.reserved, rec.Name)
+
+	now := time.Now()
+
+	stored, err := c.get(rec.Name, now)
+	switch err {
+	case nil:
+	case spider.ErrNotFound:
+		stored = &spider.Record{Name: rec.Name}
+	default:
+		return err
+	}
+
+	// a re-check without classification keeps the site of the same status
+	if rec.Site != "" || rec.Status != stored.Status {
+		stored.Site = rec.Site
+		stored.SiteDetail = rec.SiteDetail
+	}
+
+	stored.Status = rec.Status
+	stored.Backend = rec.Backend
+	stored.CheckedAt = rec.CheckedAt
+	stored.Err = rec.Err
+	stored.ExpiresAt = now.Add(c.ttlOf(stored))
+
+	if err := c.set(stored, now); err != nil {
+		return err
+	}
+	delete(c.pending, rec.Name)
+
+	return nil
+}
+
+// Release gives up the reservation of name so it can be checked again,
+// cause is kept on the record.
+func (c *Cache) Release(name string, cause error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.reserved, name)
+
+	if cause == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	rec, err := c.get(name, now)
+	switch err {
+	case nil:
+	case spider.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+
+	rec.Err = cause.Error()
+	rec.CheckedAt = now
+
+	if err := c.set(rec, now); err != nil {
+		return err
+	}
+	delete(c.pending, name)
+
+	return nil
+}
+
+// Range calls fn for each stored domain record.
+func (c *Cache) Range(fn func(rec *spider.Record) error) error {
+	now := time.Now()
+
+	c.mu.Lock()
+	err := c.flush(now)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return c.db.each("", func(key string, val []byte) error {
+		// other key spaces use a prefix
+		if strings.Contains(key, "/") {
+			return nil
+		}
+
+		var rec spider.Record
+		if err := json.Unmarshal(val, &rec); err != nil || rec.Expired(now) {
+			return nil
+		}
+		return fn(&rec)
+	})
+}
+
+// Delete the record of a domain.
+func (c *Cache) Delete(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, name)
+	if c.hot != nil {
+		c.hot.Remove(name)
+	}
+	return c.db.del(name)
+}
+
+// Import stores rec as is, ok is false if rec has already expired.
+func (c *Cache) Import(rec *spider.Record) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if rec.ExpiresAt.IsZero() {
+		from := rec.CheckedAt
+		if from.IsZero() {
+			from = now
+		}
+		rec.ExpiresAt = from.Add(c.ttlOf(rec))
+	}
+
+	if rec.Expired(now) {
+		return false, nil
+	}
+
+	delete(c.pending, rec.Name)
+
+	return true, c.set(rec, now)
+}
+
+// Compact drops expired entries and lapsed reservations,
+// it returns the number of entries dropped.
+func (c *Cache) Compact() (int, error) {
+	c.mu.Lock()
+	now := time.Now()
+	for name, until := range c.reserved {
+		if !now.Before(until) {
+			delete(c.reserved, name)
+		}
+	}
+	c.mu.Unlock()
+
+	return c.db.compact()
+}
+
+// Watch adds or updates a watched domain, watched domains never expire.
+func (c *Cache) Watch(w *spider.Watch) error {
+	b, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	return c.db.set(watchPrefix+w.Name+"."+w.TLD, b, 0)
+}
+
+// Unwatch
+func (c *Cache) Unwatch(name string) error {
+	return c.db.del(watchPrefix + name)
 }
 
-// HasChecked
-func (c *Cache) HasChecked(name string) bool {
-	// first check if domain is in cache
-	b, err := c.db.Get(name)
-	if err != nil || b == nil {
-		// if not found save to cache
-		if err := c.db.Set(name, []byte(name), c.ttl); err != nil {
-			return false
+// Due returns watched domains to re-check at t.
+func (c *Cache) Due(t time.Time) ([]*spider.Watch, error) {
+	var due []*spider.Watch
+	if err := c.db.each(watchPrefix, func(_ string, val []byte) error {
+		var w spider.Watch
+		if err := json.Unmarshal(val, &w); err != nil {
+			return err
+		}
+		if !w.NextCheck.After(t) {
+			due = append(due, &w)
 		}
-		return false
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// Visit marks link as visited for the visit ttl, seen is true if it
+// already was. links are keyed by hash to keep keys short.
+func (c *Cache) Visit(link string) (bool, error) {
+	sum := sha1.Sum([]byte(link))
+	key := urlPrefix + hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.db.get(key)
+	switch err {
+	case nil:
+		return true, nil
+	case errNoRow:
+	default:
+		return false, err
+	}
+
+	return false, c.db.set(key, []byte(link), c.visitTTL)
+}
+
+// Validators returns the http validators kept for link.
+func (c *Cache) Validators(link string) (spider.Validators, error) {
+	var v spider.Validators
+
+	b, err := c.db.get(httpKey(link))
+	switch err {
+	case nil:
+	case errNoRow:
+		return v, spider.ErrNotFound
+	default:
+		return v, err
 	}
-	return true
+
+	return v, json.Unmarshal(b, &v)
+}
+
+// SetValidators keeps the http validators of link for the http ttl.
+func (c *Cache) SetValidators(link string, v spider.Validators) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.db.set(httpKey(link), b, c.httpTTL)
+}
+
+// httpKey: links are keyed by hash to keep keys short.
+func httpKey(link string) string {
+	sum := sha1.Sum([]byte(link))
+	return httpPrefix + hex.EncodeToString(sum[:])
 }
 
-// Close
+// Stats returns the lookup counters since the store was opened.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Lookups:     atomic.LoadUint64(&c.stats.Lookups),
+		HotHits:     atomic.LoadUint64(&c.stats.HotHits),
+		BloomSkips:  atomic.LoadUint64(&c.stats.BloomSkips),
+		StoreHits:   atomic.LoadUint64(&c.stats.StoreHits),
+		StoreMisses: atomic.LoadUint64(&c.stats.StoreMisses),
+	}
+}
+
+// Close writes pending referrers, saves the bloom filter and closes the store.
 func (c *Cache) Close() error {
-	c.db.Close()
+	c.mu.Lock()
+	err := c.flush(time.Now())
+	c.mu.Unlock()
+	if err != nil {
+		c.db.close()
+		return err
+	}
+
+	if err := c.saveBloom(); err != nil {
+		c.db.close()
+		return err
+	}
+	return c.db.close()
+}
+
+// get returns the stored record of name with its pending referrers,
+// mu must be held.
+func (c *Cache) get(name string, now time.Time) (*spider.Record, error) {
+	rec, err := c.stored(name, now)
+	if err != nil {
+		if err == spider.ErrNotFound {
+			delete(c.pending, name)
+		}
+		return nil, err
+	}
+
+	if p, found := c.pending[name]; found {
+		rec.Referrers.Merge(p)
+	}
+
+	return rec, nil
+}
+
+// flush writes the pending referrers, mu must be held.
+func (c *Cache) flush(now time.Time) error {
+	c.flushed = now
+
+	for name, p := range c.pending {
+		rec, err := c.stored(name, now)
+		switch err {
+		case nil:
+		case spider.ErrNotFound:
+			delete(c.pending, name)
+			continue
+		default:
+			return err
+		}
+
+		rec.Referrers.Merge(p)
+		if err := c.set(rec, now); err != nil {
+			return err
+		}
+		delete(c.pending, name)
+	}
+
+	return nil
+}
+
+// stored looks up the hot cache, then the bloom filter, then the store.
+func (c *Cache) stored(name string, now time.Time) (*spider.Record, error) {
+	atomic.AddUint64(&c.stats.Lookups, 1)
+
+	b, err := c.lookup(name)
+	switch err {
+	case nil:
+	case errNoRow:
+		return nil, spider.ErrNotFound
+	default:
+		return nil, err
+	}
+
+	var rec spider.Record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		// written by an older version
+		return nil, spider.ErrNotFound
+	}
+
+	if rec.Expired(now) {
+		return nil, spider.ErrNotFound
+	}
+
+	return &rec, nil
+}
+
+// lookup
+func (c *Cache) lookup(name string) ([]byte, error) {
+	if c.hot != nil {
+		if b, found := c.hot.Get(name); found {
+			atomic.AddUint64(&c.stats.HotHits, 1)
+			return b, nil
+		}
+	}
+
+	if c.bloom != nil && !c.bloom.Test(name) {
+		atomic.AddUint64(&c.stats.BloomSkips, 1)
+		return nil, errNoRow
+	}
+
+	b, err := c.db.get(name)
+	switch err {
+	case nil:
+		atomic.AddUint64(&c.stats.StoreHits, 1)
+		if c.hot != nil {
+			c.hot.Add(name, b)
+		}
+	case errNoRow:
+		atomic.AddUint64(&c.stats.StoreMisses, 1)
+	}
+
+	return b, err
+}
+
+// set keeps the record until its expiry.
+func (c *Cache) set(rec *spider.Record, now time.Time) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := c.db.set(rec.Name, b, rec.ExpiresAt.Sub(now)); err != nil {
+		return err
+	}
+
+	if c.bloom != nil {
+		c.bloom.Add(rec.Name)
+	}
+	if c.hot != nil {
+		c.hot.Add(rec.Name, b)
+	}
+
 	return nil
 }
+
+// ttlOf
+func (c *Cache) ttlOf(rec *spider.Record) time.Duration {
+	if !rec.Checked() {
+		return c.ttl
+	}
+	if ttl, found := c.ttls[rec.Status]; found {
+		return ttl
+	}
+	return c.ttl
+}
@@ -0,0 +1,30 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/cache"
+	"github.com/twiny/spidy/v2/internal/service/cache/cachetest"
+)
+
+// testDriver runs the storage conformance checks on an empty store of driver.
+func testDriver(t *testing.T, driver string) {
+	t.Helper()
+
+	store, err := cache.New(cache.Config{
+		Driver: driver,
+		Path:   t.TempDir(),
+		Size:   1000,
+		TTL:    time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := cachetest.TestStorage(store); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,158 @@
+package cachetest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+)
+
+// TestStorage: conformance checks shared by all spider.Storage drivers,
+// store must be empty. it returns the first failure found.
+func TestStorage(store spider.Storage) error {
+	checks := []struct {
+		name string
+		fn   func(spider.Storage) error
+	}{
+		{"get missing", testGetMissing},
+		{"reserve once", testReserveOnce},
+		{"release", testRelease},
+		{"commit", testCommit},
+		{"referrers", testReferrers},
+	}
+
+	for _, c := range checks {
+		if err := c.fn(store); err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+
+	return nil
+}
+
+// testGetMissing
+func testGetMissing(store spider.Storage) error {
+	if _, err := store.Get("missing.com"); !errors.Is(err, spider.ErrNotFound) {
+		return fmt.Errorf("got %v, want %v", err, spider.ErrNotFound)
+	}
+	return nil
+}
+
+// testReserveOnce
+func testReserveOnce(store spider.Storage) error {
+	_, ok, err := store.Reserve("once.com", spider.Referrers{})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("first reserve was refused")
+	}
+
+	_, ok, err = store.Reserve("once.com", spider.Referrers{})
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("domain reserved twice")
+	}
+
+	return store.Release("once.com", nil)
+}
+
+// testRelease
+func testRelease(store spider.Storage) error {
+	if _, _, err := store.Reserve("release.com", spider.Referrers{}); err != nil {
+		return err
+	}
+
+	if err := store.Release("release.com", errors.New("timeout")); err != nil {
+		return err
+	}
+
+	rec, ok, err := store.Reserve("release.com", spider.Referrers{})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("released domain can not be reserved")
+	}
+	if rec.Err != "timeout" {
+		return fmt.Errorf("got error %q, want %q", rec.Err, "timeout")
+	}
+
+	return store.Release("release.com", nil)
+}
+
+// testCommit
+func testCommit(store spider.Storage) error {
+	if _, _, err := store.Reserve("commit.com", spider.Referrers{}); err != nil {
+		return err
+	}
+
+	checkedAt := time.Now().Truncate(time.Second)
+	if err := store.Commit(&spider.Record{
+		Name:      "commit.com",
+		Status:    "available",
+		Backend:   spider.BackendWHOIS,
+		CheckedAt: checkedAt,
+	}); err != nil {
+		return err
+	}
+
+	rec, err := store.Get("commit.com")
+	if err != nil {
+		return err
+	}
+	if rec.Status != "available" || rec.Backend != spider.BackendWHOIS || !rec.CheckedAt.Equal(checkedAt) {
+		return fmt.Errorf("unexpected record %+v", rec)
+	}
+
+	rec, ok, err := store.Reserve("commit.com", spider.Referrers{})
+	if err != nil {
+		return err
+	}
+	if ok || !rec.Checked() {
+		return fmt.Errorf("checked domain was reserved")
+	}
+
+	return nil
+}
+
+// testReferrers
+func testReferrers(store spider.Storage) error {
+	now := time.Now()
+	for _, host := range []string{"a.com", "b.com", "a.com"} {
+		if _, _, err := store.Reserve("refer.com", spider.Referrers{
+			Pages:     1,
+			Hosts:     []string{host},
+			FirstSeen: now,
+			LastSeen:  now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	rec, err := store.Get("refer.com")
+	if err != nil {
+		return err
+	}
+	if rec.Referrers.Pages != 3 || len(rec.Referrers.Hosts) != 2 {
+		return fmt.Errorf("got %d pages and %d hosts, want 3 and 2", rec.Referrers.Pages, len(rec.Referrers.Hosts))
+	}
+
+	if err := store.Commit(&spider.Record{Name: "refer.com", Status: "registered"}); err != nil {
+		return err
+	}
+
+	rec, err = store.Get("refer.com")
+	if err != nil {
+		return err
+	}
+	if rec.Referrers.Pages != 3 {
+		return fmt.Errorf("commit dropped referrers")
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// errNoRow: key is missing or expired.
+var errNoRow = errors.New("no row")
+
+// kv: a key/value backend, a ttl of 0 or less never expires.
+type kv interface {
+	get(key string) ([]byte, error)
+	set(key string, val []byte, ttl time.Duration) error
+	del(key string) error
+	each(prefix string, fn func(key string, val []byte) error) error
+	compact() (int, error) // drop expired keys
+	close() error
+}
+
+// pair
+type pair struct {
+	key string
+	val []byte
+}
+
+// apply fn to collected pairs.
+func apply(pairs []pair, fn func(key string, val []byte) error) error {
+	for _, p := range pairs {
+		if err := fn(p.key, p.val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiresAt
+func expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// expired
+func expired(at int64) bool {
+	return at > 0 && time.Now().UnixNano() >= at
+}
+
+// envelope prefixes val with its expiry for backends without ttl support.
+func envelope(val []byte, ttl time.Duration) []byte {
+	b := make([]byte, 8+len(val))
+	binary.BigEndian.PutUint64(b, uint64(expiresAt(ttl)))
+	copy(b[8:], val)
+	return b
+}
+
+// open an envelope, ok is false if it is invalid or expired.
+func open(b []byte) ([]byte, bool) {
+	if len(b) < 8 {
+		return nil, false
+	}
+	if expired(int64(binary.BigEndian.Uint64(b))) {
+		return nil, false
+	}
+	val := make([]byte, len(b)-8)
+	copy(val, b[8:])
+	return val, true
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+)
+
+// memoryKV: an in-memory LRU, nothing is kept after close. watched
+// domains are kept apart and never evicted.
+type memoryKV struct {
+	items  *lru.Cache[string, memoryItem]
+	mu     *sync.Mutex
+	pinned map[string]memoryItem
+}
+
+// memoryItem
+type memoryItem struct {
+	val       []byte
+	expiresAt int64
+}
+
+// newMemoryKV
+func newMemoryKV(size int) *memoryKV {
+	return &memoryKV{
+		items:  lru.New[string, memoryItem](size),
+		mu:     &sync.Mutex{},
+		pinned: map[string]memoryItem{},
+	}
+}
+
+// get
+func (m *memoryKV) get(key string) ([]byte, error) {
+	item, found := m.item(key)
+	if !found {
+		return nil, errNoRow
+	}
+	if expired(item.expiresAt) {
+		m.del(key)
+		return nil, errNoRow
+	}
+	return append([]byte(nil), item.val...), nil
+}
+
+// set
+func (m *memoryKV) set(key string, val []byte, ttl time.Duration) error {
+	item := memoryItem{
+		val:       append([]byte{}, val...),
+		expiresAt: expiresAt(ttl),
+	}
+
+	if pinned(key) {
+		m.mu.Lock()
+		m.pinned[key] = item
+		m.mu.Unlock()
+		return nil
+	}
+
+	m.items.Add(key, item)
+	return nil
+}
+
+// del
+func (m *memoryKV) del(key string) error {
+	if pinned(key) {
+		m.mu.Lock()
+		delete(m.pinned, key)
+		m.mu.Unlock()
+		return nil
+	}
+
+	m.items.Remove(key)
+	return nil
+}
+
+// each
+func (m *memoryKV) each(prefix string, fn func(key string, val []byte) error) error {
+	// copy first, fn may write to the cache
+	var pairs []pair
+	m.rangeAll(func(key string, item memoryItem) {
+		if strings.HasPrefix(key, prefix) && !expired(item.expiresAt) {
+			pairs = append(pairs, pair{key, append([]byte(nil), item.val...)})
+		}
+	})
+
+	return apply(pairs, fn)
+}
+
+// compact
+func (m *memoryKV) compact() (int, error) {
+	var keys []string
+	m.rangeAll(func(key string, item memoryItem) {
+		if expired(item.expiresAt) {
+			keys = append(keys, key)
+		}
+	})
+
+	for _, key := range keys {
+		m.del(key)
+	}
+	return len(keys), nil
+}
+
+// close
+func (m *memoryKV) close() error {
+	return nil
+}
+
+// item
+func (m *memoryKV) item(key string) (memoryItem, bool) {
+	if pinned(key) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		item, found := m.pinned[key]
+		return item, found
+	}
+
+	return m.items.Get(key)
+}
+
+// rangeAll calls fn for the pinned items then the LRU ones.
+func (m *memoryKV) rangeAll(fn func(key string, item memoryItem)) {
+	m.mu.Lock()
+	for key, item := range m.pinned {
+		fn(key, item)
+	}
+	m.mu.Unlock()
+
+	m.items.Range(func(key string, item memoryItem) bool {
+		fn(key, item)
+		return true
+	})
+}
+
+// pinned: watched domains must not be evicted.
+func pinned(key string) bool {
+	return strings.HasPrefix(key, watchPrefix)
+}
@@ -0,0 +1,13 @@
+package cache_test
+
+import (
+	"testing"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/cache"
+)
+
+// TestMemory
+func TestMemory(t *testing.T) {
+	testDriver(t, cache.DriverMemory)
+}
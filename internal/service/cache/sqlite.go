@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"database/sql"
+	"time"
+
+	//
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqlite statements
+const (
+	sqliteSchema = `CREATE TABLE IF NOT EXISTS kv (
+		key        TEXT PRIMARY KEY,
+		val        BLOB NOT NULL,
+		expires_at INTEGER NOT NULL DEFAULT 0
+	)`
+	sqliteGet     = `SELECT val FROM kv WHERE key = ? AND (expires_at = 0 OR expires_at > ?)`
+	sqliteSet     = `INSERT INTO kv (key, val, expires_at) VALUES (?, ?, ?) ON CONFLICT(key) DO UPDATE SET val = excluded.val, expires_at = excluded.expires_at`
+	sqliteDel     = `DELETE FROM kv WHERE key = ?`
+	sqliteCompact = `DELETE FROM kv WHERE expires_at > 0 AND expires_at <= ?`
+	sqliteEach    = `SELECT key, val FROM kv WHERE substr(key, 1, ?) = ? AND (expires_at = 0 OR expires_at > ?) ORDER BY key`
+)
+
+// sqliteKV: a single file store that can be queried by other tools.
+type sqliteKV struct {
+	db *sql.DB
+}
+
+// newSQLiteKV
+func newSQLiteKV(fp string) (*sqliteKV, error) {
+	db, err := sql.Open("sqlite3", "file:"+fp+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	// a single writer avoids lock errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteKV{db: db}, nil
+}
+
+// get
+func (s *sqliteKV) get(key string) ([]byte, error) {
+	var val []byte
+	err := s.db.QueryRow(sqliteGet, key, time.Now().UnixNano()).Scan(&val)
+	if err == sql.ErrNoRows {
+		return nil, errNoRow
+	}
+	return val, err
+}
+
+// set
+func (s *sqliteKV) set(key string, val []byte, ttl time.Duration) error {
+	_, err := s.db.Exec(sqliteSet, key, val, expiresAt(ttl))
+	return err
+}
+
+// del
+func (s *sqliteKV) del(key string) error {
+	_, err := s.db.Exec(sqliteDel, key)
+	return err
+}
+
+// each
+func (s *sqliteKV) each(prefix string, fn func(key string, val []byte) error) error {
+	// collect first, the connection is needed by fn
+	rows, err := s.db.Query(sqliteEach, len(prefix), prefix, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+
+	var pairs []pair
+	for rows.Next() {
+		var p pair
+		if err := rows.Scan(&p.key, &p.val); err != nil {
+			rows.Close()
+			return err
+		}
+		pairs = append(pairs, p)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return apply(pairs, fn)
+}
+
+// compact
+func (s *sqliteKV) compact() (int, error) {
+	res, err := s.db.Exec(sqliteCompact, time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := s.db.Exec(`VACUUM`); err != nil {
+		return int(n), err
+	}
+	return int(n), nil
+}
+
+// close
+func (s *sqliteKV) close() error {
+	return s.db.Close()
+}
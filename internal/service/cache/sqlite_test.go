@@ -0,0 +1,15 @@
+//go:build cgo
+
+package cache_test
+
+import (
+	"testing"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/cache"
+)
+
+// TestSQLite
+func TestSQLite(t *testing.T) {
+	testDriver(t, cache.DriverSQLite)
+}
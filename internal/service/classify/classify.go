@@ -0,0 +1,164 @@
+package classify
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/guard"
+	"github.com/twiny/spidy/v2/internal/pkg/signature"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"golang.org/x/net/publicsuffix"
+)
+
+// classes of a registered domain homepage
+const (
+	ClassLive     = "live"
+	ClassRedirect = "redirect" // to another domain
+	ClassParked   = spider.SiteParked
+	ClassForSale  = spider.SiteForSale
+)
+
+// defaults
+const (
+	defaultTimeout = 15 * time.Second
+	maxBodySize    = 512 * 1024 // of a homepage
+	maxRedirects   = 10
+)
+
+// Config
+type Config struct {
+	Timeout   time.Duration // of a classification
+	UserAgent string
+	Signs     *signature.Set // the built-in ones if nil
+	Guard     *guard.Guard   // optional, rejects connections to reserved ips
+}
+
+// Result: Detail is the matched signature or the redirect target.
+type Result struct {
+	Class  string
+	Detail string
+}
+
+// Classifier fetches the homepage of a registered domain and tells
+// parked, for sale, redirecting and live sites apart.
+type Classifier struct {
+	conf     Config
+	resolver *net.Resolver
+	client   *http.Client
+}
+
+// NewClassifier
+func NewClassifier(conf Config) *Classifier {
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+
+	if conf.Signs == nil {
+		conf.Signs = signature.Default()
+	}
+
+	return &Classifier{
+		conf:     conf,
+		resolver: net.DefaultResolver,
+		client: &http.Client{
+			Transport: conf.Guard.Transport(),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Classify domain, an error is returned if it could not be
+// classified, e.g. its homepage is unreachable.
+func (c *Classifier) Classify(ctx context.Context, domain string) (Result, error) {
+	domain = strings.ToLower(domain)
+
+	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	defer cancel()
+
+	// parking name servers
+	var parkedBy string
+	if nss, err := c.resolver.LookupNS(ctx, domain); err == nil {
+		for _, ns := range nss {
+			if sig, ok := c.conf.Signs.Nameserver(ns.Host); ok {
+				parkedBy = sig
+				break
+			}
+		}
+	}
+
+	host, body, err := c.homepage(ctx, domain)
+	if err != nil {
+		if parkedBy != "" {
+			return Result{Class: ClassParked, Detail: parkedBy}, nil
+		}
+		return Result{}, err
+	}
+
+	if sig, ok := c.conf.Signs.Marketplace(host); ok {
+		return Result{Class: ClassForSale, Detail: sig}, nil
+	}
+
+	if sig, ok := c.conf.Signs.ForSale(body); ok {
+		return Result{Class: ClassForSale, Detail: sig}, nil
+	}
+
+	if parkedBy != "" {
+		return Result{Class: ClassParked, Detail: parkedBy}, nil
+	}
+
+	if sig, ok := c.conf.Signs.Parked(body); ok {
+		return Result{Class: ClassParked, Detail: sig}, nil
+	}
+
+	if root, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil && root != domain {
+		return Result{Class: ClassRedirect, Detail: host}, nil
+	}
+
+	return Result{Class: ClassLive}, nil
+}
+
+// homepage of domain over https then http, it returns the host
+// redirects ended on and the page body.
+func (c *Classifier) homepage(ctx context.Context, domain string) (string, []byte, error) {
+	host, body, err := c.get(ctx, "https://"+domain+"/")
+	if err == nil {
+		return host, body, nil
+	}
+	return c.get(ctx, "http://"+domain+"/")
+}
+
+// get
+func (c *Classifier) get(ctx context.Context, link string) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if c.conf.UserAgent != "" {
+		req.Header.Set("User-Agent", c.conf.UserAgent)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return strings.ToLower(resp.Request.URL.Hostname()), body, nil
+}
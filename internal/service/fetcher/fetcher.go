@@ -0,0 +1,523 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/guard"
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+	"github.com/twiny/spidy/v2/internal/pkg/robots"
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+
+	//
+	"github.com/PuerkitoBio/goquery"
+	"github.com/twiny/wbot"
+)
+
+// Errs
+var (
+	ErrDisallowed = errors.New("disallowed by robots.txt")
+)
+
+// defaults
+const (
+	defaultUserAgent   = `wbot/0.1`
+	defaultTimeout     = 5 * time.Second
+	defaultMaxBodySize = 10 * 1024 * 1024
+	maxRobotsSize      = 512 * 1024 // larger robots.txt files are truncated
+	maxHosts           = 100000     // hosts kept in the robots and delay caches
+	robotsRetry        = 10 * time.Minute
+	maxDrain           = 64 * 1024 // of a retried response, to reuse its connection
+	maxUnhandled       = 10000     // fetched pages whose validators are not kept yet
+)
+
+// Config
+type Config struct {
+	Timeout      time.Duration
+	Robots       bool               // honor robots.txt
+	RobotsTTL    time.Duration      // how long a robots.txt is cached
+	RobotsIgnore []string           // hosts, and their subdomains, robots.txt is ignored for
+	MaxDelay     time.Duration      // crawl-delay cap
+	Validators   spider.Conditional // optional, validators of conditional requests
+	Retry        Retry
+	Request      Request
+	Guard        *guard.Guard                       // optional, rejects connections to reserved ips
+	Backoff      func(host string, until time.Time) // optional, called when a host answers 429 or has a crawl-delay
+}
+
+// Fetcher: a wbot.Fetcher that honors robots.txt and its crawl-delay.
+type Fetcher struct {
+	stats   uint64 // disallowed urls, first for 64-bit atomic alignment
+	unmod   uint64 // pages not modified
+	retries uint64
+	conf    Config
+	mu      *sync.Mutex
+	clients map[string]*http.Client // by proxy
+	robots  *lru.Cache[string, *robotsEntry]
+	next    *lru.Cache[string, time.Time]         // next fetch by host
+	backoff *lru.Cache[string, backoff]           // hosts answering 429
+	fresh   *lru.Cache[string, spider.Validators] // validators of pages not handled yet
+}
+
+// robotsEntry is fetched once per ttl.
+type robotsEntry struct {
+	once    *sync.Once
+	rules   *robots.Robots
+	expires time.Time
+}
+
+// NewFetcher
+func NewFetcher(conf Config) *Fetcher {
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+	if conf.Retry.Backoff <= 0 {
+		conf.Retry.Backoff = defaultBackoff
+	}
+	if conf.Retry.MaxWait <= 0 {
+		conf.Retry.MaxWait = defaultMaxWait
+	}
+	if conf.Retry.MaxBackoff <= 0 {
+		conf.Retry.MaxBackoff = defaultMaxBackoff
+	}
+
+	return &Fetcher{
+		conf:    conf,
+		mu:      &sync.Mutex{},
+		clients: map[string]*http.Client{},
+		robots:  lru.New[string, *robotsEntry](maxHosts),
+		next:    lru.New[string, time.Time](maxHosts),
+		backoff: lru.New[string, backoff](maxHosts),
+		fresh:   lru.New[string, spider.Validators](maxUnhandled),
+	}
+}
+
+// Fetch
+func (f *Fetcher) Fetch(req wbot.Request) (wbot.Response, error) {
+	var (
+		userAgent   = defaultUserAgent
+		maxBodySize = int64(defaultMaxBodySize)
+	)
+
+	if req.Param.UserAgent != "" {
+		userAgent = req.Param.UserAgent
+	}
+
+	if req.Param.MaxBodySize > 0 {
+		maxBodySize = req.Param.MaxBodySize
+	}
+
+	cli := f.client(req.Param.Proxy)
+
+	if err := f.allowed(cli, req.URL, userAgent); err != nil {
+		return wbot.Response{URL: req.URL, Depth: req.Depth}, err
+	}
+
+	// add headers
+	var header = make(http.Header)
+	header.Set("User-Agent", userAgent)
+	header.Set("Referer", req.Param.Referer)
+	f.conf.Request.decorate(header, req.URL)
+
+	link := req.URL.String()
+
+	var stored spider.Validators
+	if f.conf.Validators != nil {
+		if v, err := f.conf.Validators.Validators(link); err == nil {
+			stored = v
+			if v.ETag != "" {
+				header.Set("If-None-Match", v.ETag)
+			}
+			if v.LastModified != "" {
+				header.Set("If-Modified-Since", v.LastModified)
+			}
+		}
+	}
+
+	host := strings.ToLower(req.URL.Hostname())
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		f.hold(host)
+
+		resp, err = cli.Do(&http.Request{
+			Method:     http.MethodGet,
+			URL:        req.URL,
+			Header:     header,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+		})
+
+		wait, ok := f.retry(host, resp, err, attempt)
+		if !ok {
+			break
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+			resp.Body.Close()
+		}
+
+		atomic.AddUint64(&f.retries, 1)
+		time.Sleep(wait)
+	}
+	if err != nil {
+		return wbot.Response{}, err
+	}
+	defer resp.Body.Close()
+
+	// unchanged since the last fetch, nothing to extract
+	// but its links are followed again
+	if resp.StatusCode == http.StatusNotModified {
+		atomic.AddUint64(&f.unmod, 1)
+		return wbot.Response{
+			URL:      req.URL,
+			Status:   resp.StatusCode,
+			NextURLs: stored.Links,
+			Depth:    req.Depth,
+		}, nil
+	}
+
+	// limit response body reading
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return wbot.Response{}, err
+	}
+
+	links := findLinks(body)
+
+	// kept by Handled once the page is processed
+	if f.conf.Validators != nil && resp.StatusCode == http.StatusOK {
+		v := spider.Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Links:        links,
+		}
+		if v.ETag != "" || v.LastModified != "" {
+			f.fresh.Add(link, v)
+		}
+	}
+
+	return wbot.Response{
+		URL:      req.URL,
+		Status:   resp.StatusCode,
+		Body:     body,
+		NextURLs: links,
+		Depth:    req.Depth,
+	}, nil
+}
+
+// Handled keeps the validators of a fetched page once its domains were
+// processed, so a crawl halted before cannot leave it unchecked.
+func (f *Fetcher) Handled(res wbot.Response) error {
+	if f.conf.Validators == nil || res.URL == nil {
+		return nil
+	}
+
+	link := res.URL.String()
+
+	v, found := f.fresh.Get(link)
+	if !found {
+		return nil
+	}
+	f.fresh.Remove(link)
+
+	return f.conf.Validators.SetValidators(link, v)
+}
+
+// Get fetches link outside the crawl, e.g. a sitemap, as a page: robots.txt,
+// its crawl-delay and the configured headers, cookies and credentials apply.
+// the caller closes the body.
+func (f *Fetcher) Get(ctx context.Context, link, userAgent string) (*http.Response, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+
+	cli := f.client("")
+
+	if err := f.allowed(cli, u, userAgent); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	f.conf.Request.decorate(req.Header, u)
+
+	return cli.Do(req)
+}
+
+// allowed checks u against the robots.txt of its host and
+// applies its crawl-delay.
+func (f *Fetcher) allowed(cli *http.Client, u *url.URL, userAgent string) error {
+	if !f.conf.Robots || f.ignored(u.Hostname()) {
+		return nil
+	}
+
+	rules := f.rules(cli, u, userAgent)
+
+	if !rules.Allowed(userAgent, requestPath(u)) {
+		atomic.AddUint64(&f.stats, 1)
+		return fmt.Errorf("%w: %s", ErrDisallowed, u)
+	}
+
+	f.wait(u.Hostname(), rules.Delay(userAgent))
+
+	return nil
+}
+
+// Sitemaps returns the sitemaps listed in the robots.txt of the host of u.
+func (f *Fetcher) Sitemaps(u *url.URL, userAgent string) []string {
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	return f.rules(f.client(""), u, userAgent).Sitemaps
+}
+
+// Disallowed returns the number of urls disallowed by robots.txt.
+func (f *Fetcher) Disallowed() uint64 {
+	return atomic.LoadUint64(&f.stats)
+}
+
+// NotModified returns the number of pages not modified since the last fetch.
+func (f *Fetcher) NotModified() uint64 {
+	return atomic.LoadUint64(&f.unmod)
+}
+
+// Retries returns the number of fetches retried.
+func (f *Fetcher) Retries() uint64 {
+	return atomic.LoadUint64(&f.retries)
+}
+
+// Close
+func (f *Fetcher) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, cli := range f.clients {
+		cli.CloseIdleConnections()
+	}
+	return nil
+}
+
+// client returns a shared client by proxy.
+func (f *Fetcher) client(proxy string) *http.Client {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if cli, found := f.clients[proxy]; found {
+		return cli
+	}
+
+	cli := &http.Client{
+		Transport:     newTransport(proxy, f.conf.Guard),
+		CheckRedirect: f.conf.Request.redirect,
+		Timeout:       f.conf.Timeout,
+		Jar:           f.conf.Request.Jar,
+	}
+	f.clients[proxy] = cli
+
+	return cli
+}
+
+// Proxies drops the clients of proxies no longer in use and closes their
+// idle connections, direct requests keep their client.
+func (f *Fetcher) Proxies(proxies []string) {
+	keep := make(map[string]bool, len(proxies)+1)
+	keep[""] = true
+	for _, proxy := range proxies {
+		keep[strings.TrimSpace(proxy)] = true
+	}
+
+	var dropped []*http.Client
+
+	f.mu.Lock()
+	for proxy, cli := range f.clients {
+		if !keep[proxy] {
+			delete(f.clients, proxy)
+			dropped = append(dropped, cli)
+		}
+	}
+	f.mu.Unlock()
+
+	for _, cli := range dropped {
+		cli.CloseIdleConnections()
+	}
+}
+
+// rules returns the cached robots.txt of the host of u.
+func (f *Fetcher) rules(cli *http.Client, u *url.URL, userAgent string) *robots.Robots {
+	key := u.Scheme + "://" + u.Host
+
+	f.mu.Lock()
+	entry, found := f.robots.Get(key)
+	// expires is zero while the entry is being fetched
+	if !found || (!entry.expires.IsZero() && time.Now().After(entry.expires)) {
+		entry = &robotsEntry{once: &sync.Once{}}
+		f.robots.Add(key, entry)
+	}
+	f.mu.Unlock()
+
+	entry.once.Do(func() {
+		rules, ttl := f.fetchRobots(cli, key, userAgent)
+		entry.rules = rules
+
+		f.mu.Lock()
+		entry.expires = time.Now().Add(ttl)
+		f.mu.Unlock()
+	})
+
+	return entry.rules
+}
+
+// fetchRobots: a missing robots.txt allows everything, an unreachable
+// one disallows everything until it is retried.
+func (f *Fetcher) fetchRobots(cli *http.Client, base, userAgent string) (*robots.Robots, time.Duration) {
+	retry := robotsRetry
+	if f.conf.RobotsTTL < retry {
+		retry = f.conf.RobotsTTL
+	}
+
+	hreq, err := http.NewRequest(http.MethodGet, base+"/robots.txt", nil)
+	if err != nil {
+		return robots.DisallowAll(), retry
+	}
+	hreq.Header.Set("User-Agent", userAgent)
+
+	resp, err := cli.Do(hreq)
+	if err != nil {
+		return robots.DisallowAll(), retry
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+		if err != nil {
+			return robots.DisallowAll(), retry
+		}
+		return robots.Parse(body), f.conf.RobotsTTL
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robots.AllowAll(), f.conf.RobotsTTL
+	default:
+		return robots.DisallowAll(), retry
+	}
+}
+
+// wait: the crawl-delay of host. with a scheduler the next request to host
+// is held back by Backoff, no worker sleeps on it, else the request is held
+// here until the delay has passed.
+func (f *Fetcher) wait(host string, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	if f.conf.MaxDelay > 0 && delay > f.conf.MaxDelay {
+		delay = f.conf.MaxDelay
+	}
+
+	if f.conf.Backoff != nil {
+		f.conf.Backoff(host, time.Now().Add(delay))
+		return
+	}
+
+	f.mu.Lock()
+	now := time.Now()
+	at, _ := f.next.Get(host)
+	if at.Before(now) {
+		at = now
+	}
+	f.next.Add(host, at.Add(delay))
+	f.mu.Unlock()
+
+	time.Sleep(time.Until(at))
+}
+
+// ignored
+func (f *Fetcher) ignored(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range f.conf.RobotsIgnore {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestPath: path and query as matched by robots.txt rules.
+func requestPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
+
+// newTransport: the target of a proxied request is checked by g
+// before it is sent, direct connections when they are dialed.
+func newTransport(proxy string, g *guard.Guard) http.RoundTripper {
+	var proxyFn = http.ProxyFromEnvironment
+
+	if proxy != "" {
+		proxyFn = func(req *http.Request) (*url.URL, error) {
+			return url.Parse(proxy)
+		}
+	}
+
+	return g.Wrap(&http.Transport{
+		Proxy: g.Proxy(proxyFn),
+		DialContext: g.Dial(&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   2,
+		IdleConnTimeout:       10 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	})
+}
+
+// findLinks returns the href of each link in body.
+func findLinks(body []byte) []string {
+	var hrefs []string
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return hrefs
+	}
+
+	doc.Find("a[href]").Each(func(_ int, item *goquery.Selection) {
+		if href, found := item.Attr("href"); found {
+			hrefs = append(hrefs, href)
+		}
+	})
+
+	return hrefs
+}
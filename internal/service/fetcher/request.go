@@ -0,0 +1,96 @@
+package fetcher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// maxRedirects: followed per request, as the default client.
+const maxRedirects = 10
+
+// Request: what is added to each page request.
+type Request struct {
+	Header http.Header    // sent to every host
+	Hosts  []HostRequest  // by host pattern
+	Jar    http.CookieJar // optional
+}
+
+// HostRequest: headers and credentials of hosts matching Pattern,
+// e.g. *.example.com. Token is sent as a bearer token, else
+// Username and Password as basic auth.
+type HostRequest struct {
+	Pattern  string
+	Header   http.Header
+	Username string
+	Password string
+	Token    string
+}
+
+// decorate header of a request to u, the headers of every matching
+// host pattern are added in order, the first credentials found win.
+func (r Request) decorate(header http.Header, u *url.URL) {
+	for k, vs := range r.Header {
+		header[k] = vs
+	}
+
+	var auth bool
+	for _, h := range r.Hosts {
+		if !h.match(u) {
+			continue
+		}
+
+		for k, vs := range h.Header {
+			header[k] = vs
+		}
+
+		if auth {
+			continue
+		}
+
+		switch {
+		case h.Token != "":
+			header.Set("Authorization", "Bearer "+h.Token)
+			auth = true
+		case h.Username != "" || h.Password != "":
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(h.Username+":"+h.Password)))
+			auth = true
+		}
+	}
+}
+
+// redirect is the CheckRedirect of page requests: the headers and
+// credentials of host patterns the target no longer matches are
+// removed, those of the patterns it matches are added.
+func (r Request) redirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	for _, h := range r.Hosts {
+		if h.match(req.URL) {
+			continue
+		}
+
+		for k := range h.Header {
+			req.Header.Del(k)
+		}
+
+		if h.Token != "" || h.Username != "" || h.Password != "" {
+			req.Header.Del("Authorization")
+		}
+	}
+
+	r.decorate(req.Header, req.URL)
+
+	return nil
+}
+
+// match reports whether the host of u matches the pattern of h.
+func (h HostRequest) match(u *url.URL) bool {
+	ok, _ := path.Match(strings.ToLower(h.Pattern), strings.ToLower(u.Hostname()))
+	return ok
+}
@@ -0,0 +1,141 @@
+package fetcher
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaults
+const (
+	defaultBackoff    = time.Second
+	defaultMaxWait    = 30 * time.Second
+	defaultMaxBackoff = 10 * time.Minute
+)
+
+// Retry: how failed fetches are retried.
+type Retry struct {
+	Attempts   int           // retries after the first attempt
+	Statuses   []int         // retried statuses, e.g. 429 and 503
+	Backoff    time.Duration // first wait, doubled on each retry
+	MaxWait    time.Duration // longer waits, e.g. from Retry-After, are not retried
+	MaxBackoff time.Duration // cap of the backoff of a host answering 429
+}
+
+// backoff: a host answering 429.
+type backoff struct {
+	until   time.Time
+	strikes int // 429 in a row
+}
+
+// retry reports whether a fetch that failed with err or answered resp
+// should be retried after wait. a 429 also backs off the whole host.
+func (f *Fetcher) retry(host string, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		f.throttle(host, retryAfter(resp))
+	} else if err == nil {
+		f.recover(host)
+	}
+
+	if err == nil && !f.retried(resp.StatusCode) {
+		return 0, false
+	}
+
+	if attempt >= f.conf.Retry.Attempts {
+		return 0, false
+	}
+
+	wait := f.conf.Retry.Backoff << uint(attempt)
+	if err == nil {
+		if after := retryAfter(resp); after > 0 {
+			wait = after
+		}
+	}
+	// jitter up to a quarter
+	if wait > 0 {
+		wait += time.Duration(rand.Int63n(int64(wait)/4 + 1))
+	}
+
+	if wait > f.conf.Retry.MaxWait {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// retried
+func (f *Fetcher) retried(status int) bool {
+	for _, s := range f.conf.Retry.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// throttle backs off host, for after if given or else exponentially.
+func (f *Fetcher) throttle(host string, after time.Duration) {
+	f.mu.Lock()
+	b, _ := f.backoff.Get(host)
+	b.strikes++
+
+	d := after
+	if d <= 0 {
+		d = f.conf.Retry.Backoff << uint(b.strikes-1)
+	}
+	if d <= 0 || d > f.conf.Retry.MaxBackoff {
+		d = f.conf.Retry.MaxBackoff
+	}
+
+	b.until = time.Now().Add(d)
+	f.backoff.Add(host, b)
+	f.mu.Unlock()
+
+	if f.conf.Backoff != nil {
+		f.conf.Backoff(host, b.until)
+	}
+}
+
+// recover ends the backoff of host.
+func (f *Fetcher) recover(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if b, found := f.backoff.Get(host); found && b.strikes > 0 {
+		f.backoff.Remove(host)
+	}
+}
+
+// hold waits for the backoff of host to end.
+func (f *Fetcher) hold(host string) {
+	f.mu.Lock()
+	b, _ := f.backoff.Get(host)
+	f.mu.Unlock()
+
+	if d := time.Until(b.until); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// retryAfter parses the Retry-After header, in seconds or as a date.
+func retryAfter(resp *http.Response) time.Duration {
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
@@ -0,0 +1,532 @@
+package frontier
+
+import (
+	"container/heap"
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+
+	//
+	"github.com/twiny/wbot"
+)
+
+// Errs
+var (
+	ErrEmpty = errors.New("frontier is empty")
+)
+
+// maxIdle: idle hosts whose last request time is kept.
+const maxIdle = 100000
+
+// skip: links the wbot filter rejects after Dequeue, they are dropped at
+// Enqueue so a dequeued request always reaches Store.Visited and Fetch.
+var skip = regexp.MustCompile(`^.*\.(png|jpg|jpeg|gif|ico|eps|pdf|iso|mp3|mp4|zip|aif|mpa|wav|wma|7z|deb|pkg|rar|rpm|bin|dmg|dat|tar|exe|ps|psd|svg|tif|tiff|pps|ppt|pptx|xls|xlsx|wmv|doc|docx|txt|mov|mpl)$`)
+
+// Policy: how politely a host is crawled.
+type Policy struct {
+	Delay    time.Duration // between two requests
+	MaxConns int           // concurrent requests
+}
+
+// Rule overrides the policy of hosts matching Pattern, e.g. *.example.com.
+type Rule struct {
+	Pattern string
+	Policy  Policy
+}
+
+// Scorer ranks requests, higher scores are fetched first.
+type Scorer interface {
+	Score(req wbot.Request) float64
+}
+
+// Config
+type Config struct {
+	Workers  int   // crawl workers calling Next
+	MaxDepth int32 // deeper requests are dropped
+	Policy   Policy
+	Rules    []Rule
+	Rate     int // global budget of Rate requests per Interval, 0 means none
+	Interval time.Duration
+	Allow    func(host string) bool      // optional, requests to disallowed hosts are dropped
+	Admit    func(req wbot.Request) bool // optional, refused requests are dropped
+	Scorer   Scorer                      // optional, requests are fetched in order without
+}
+
+// Frontier: a wbot.Queue scheduling requests by host. each host has its own
+// queue ranked by score, delay and connection limit. Dequeue returns the best
+// request of the hosts ready now. Next blocks while no host is ready and a
+// worker may still add links, so workers only stop once every one of them
+// is idle.
+type Frontier struct {
+	mu      *sync.Mutex
+	cond    *sync.Cond
+	conf    Config
+	every   time.Duration // global interval between two requests
+	hosts   map[string]*host
+	ready   *hostHeap // hosts ready now, by best score
+	timed   *hostHeap // hosts waiting for their delay, by next request time
+	idle    *lru.Cache[string, time.Time]
+	queued  int
+	seq     uint64    // enqueue order, breaks score ties
+	global  time.Time // next request allowed by the global budget
+	timer   *time.Timer
+	wake    time.Time
+	waiting int // workers blocked in Next
+	drained bool
+	closed  bool
+}
+
+// host
+type host struct {
+	name   string
+	policy Policy
+	q      reqHeap
+	next   time.Time // next request allowed
+	active int       // requests being fetched
+	heap   *hostHeap // ready, timed or nil
+	index  int
+}
+
+// item: a queued request.
+type item struct {
+	req   wbot.Request
+	score float64
+	seq   uint64
+}
+
+// New
+func New(conf Config) *Frontier {
+	if conf.Policy.MaxConns <= 0 {
+		conf.Policy.MaxConns = 1
+	}
+
+	var every time.Duration
+	if conf.Rate > 0 {
+		every = conf.Interval / time.Duration(conf.Rate)
+	}
+
+	mu := &sync.Mutex{}
+	return &Frontier{
+		mu:    mu,
+		cond:  sync.NewCond(mu),
+		conf:  conf,
+		every: every,
+		hosts: map[string]*host{},
+		ready: &hostHeap{less: func(a, b *host) bool { return a.q.before(b.q) }},
+		timed: &hostHeap{less: func(a, b *host) bool { return a.next.Before(b.next) }},
+		idle:  lru.New[string, time.Time](maxIdle),
+	}
+}
+
+// Enqueue
+func (f *Frontier) Enqueue(req wbot.Request) error {
+	// wbot stops a worker on a request deeper than max depth
+	if req.Depth > f.conf.MaxDepth || skip.MatchString(req.URL.Path) {
+		return nil
+	}
+
+	// scored outside the lock
+	var score float64
+	if f.conf.Scorer != nil {
+		score = f.conf.Scorer.Score(req)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+
+	name := strings.ToLower(req.URL.Hostname())
+	if !f.allow(name) || !f.admit(req) {
+		return nil
+	}
+
+	f.seq++
+
+	h := f.host(name)
+	heap.Push(&h.q, &item{req: req, score: score, seq: f.seq})
+	f.queued++
+
+	if h.heap == f.ready {
+		// its best request may have changed
+		heap.Fix(f.ready, h.index)
+	}
+	f.schedule(h, time.Now())
+
+	f.cond.Signal()
+
+	return nil
+}
+
+// Dequeue returns the best request of the hosts ready now.
+// the host stays busy until Done is called.
+func (f *Frontier) Dequeue() (wbot.Request, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+
+	var (
+		h  *host
+		it *item
+	)
+	for it == nil {
+		if _, ok := f.peek(now); !ok {
+			return wbot.Request{}, ErrEmpty
+		}
+
+		// drop hosts disallowed since their requests were queued
+		h = f.ready.top()
+		if !f.allow(h.name) {
+			f.drop(h)
+			continue
+		}
+
+		it = heap.Pop(&h.q).(*item)
+		f.queued--
+
+		// and requests refused since
+		if !f.admit(it.req) {
+			it = nil
+			f.unschedule(h)
+			f.schedule(h, now)
+			f.forget(h)
+		}
+	}
+
+	h.active++
+	h.next = now.Add(h.policy.Delay)
+
+	if f.every > 0 {
+		if f.global.Before(now) {
+			f.global = now
+		}
+		f.global = f.global.Add(f.every)
+	}
+
+	f.unschedule(h)
+	f.schedule(h, now)
+
+	return it.req, nil
+}
+
+// Done releases the connection of a request to host.
+func (f *Frontier) Done(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h, found := f.hosts[strings.ToLower(name)]
+	if !found {
+		return
+	}
+
+	if h.active > 0 {
+		h.active--
+	}
+
+	f.schedule(h, time.Now())
+	f.forget(h)
+
+	f.cond.Broadcast()
+}
+
+// Backoff holds the requests to host until the given time.
+func (f *Frontier) Backoff(name string, until time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = strings.ToLower(name)
+
+	h, found := f.hosts[name]
+	if !found {
+		if next, _ := f.idle.Get(name); until.After(next) {
+			f.idle.Add(name, until)
+		}
+		return
+	}
+
+	if !until.After(h.next) {
+		return
+	}
+	h.next = until
+
+	f.unschedule(h)
+	f.schedule(h, time.Now())
+}
+
+// Next blocks until a request is ready, it returns false
+// once the frontier is closed or every worker is idle.
+func (f *Frontier) Next() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.waiting++
+	defer func() { f.waiting-- }()
+
+	for {
+		if f.closed || f.drained {
+			return false
+		}
+
+		wait, ok := f.peek(time.Now())
+		if ok {
+			return true
+		}
+
+		if f.queued == 0 && f.waiting >= f.conf.Workers {
+			f.drained = true
+			f.cond.Broadcast()
+			return false
+		}
+
+		if wait > 0 {
+			f.wakeIn(wait)
+		}
+		f.cond.Wait()
+	}
+}
+
+// Len
+func (f *Frontier) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queued
+}
+
+// Close stops the workers, queued requests are dropped.
+func (f *Frontier) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	f.hosts = map[string]*host{}
+	f.ready.hosts = nil
+	f.timed.hosts = nil
+	f.queued = 0
+
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+
+	f.cond.Broadcast()
+
+	return nil
+}
+
+// peek reports whether a request is ready at now, or how long until one may be.
+func (f *Frontier) peek(now time.Time) (time.Duration, bool) {
+	// hosts whose delay has passed
+	for f.timed.Len() > 0 && !f.timed.top().next.After(now) {
+		h := f.timed.top()
+		f.unschedule(h)
+		f.schedule(h, now)
+	}
+
+	var at time.Time
+	switch {
+	case f.ready.Len() > 0:
+		at = now
+	case f.timed.Len() > 0:
+		at = f.timed.top().next
+	default:
+		return 0, false
+	}
+
+	if f.global.After(at) {
+		at = f.global
+	}
+
+	if !at.After(now) {
+		return 0, true
+	}
+
+	return at.Sub(now), false
+}
+
+// wakeIn wakes up waiting workers after d.
+func (f *Frontier) wakeIn(d time.Duration) {
+	at := time.Now().Add(d)
+	if f.timer != nil && !at.Before(f.wake) {
+		return
+	}
+
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+
+	f.wake = at
+	f.timer = time.AfterFunc(d, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.timer = nil
+		f.cond.Broadcast()
+	})
+}
+
+// host returns the state of name, idle hosts keep their last request time.
+func (f *Frontier) host(name string) *host {
+	if h, found := f.hosts[name]; found {
+		return h
+	}
+
+	h := &host{
+		name:   name,
+		policy: f.policy(name),
+		index:  -1,
+	}
+
+	if next, found := f.idle.Get(name); found {
+		h.next = next
+		f.idle.Remove(name)
+	}
+
+	f.hosts[name] = h
+
+	return h
+}
+
+// allow
+func (f *Frontier) allow(name string) bool {
+	return f.conf.Allow == nil || f.conf.Allow(name)
+}
+
+// admit
+func (f *Frontier) admit(req wbot.Request) bool {
+	return f.conf.Admit == nil || f.conf.Admit(req)
+}
+
+// drop the queued requests of h.
+func (f *Frontier) drop(h *host) {
+	f.queued -= len(h.q)
+	h.q = nil
+
+	f.unschedule(h)
+	f.forget(h)
+}
+
+// schedule h if it has a request and a free connection, in the ready heap
+// if its delay has passed or else in the timed heap.
+func (f *Frontier) schedule(h *host, now time.Time) {
+	if h.heap != nil || len(h.q) == 0 || h.active >= h.policy.MaxConns {
+		return
+	}
+
+	if h.next.After(now) {
+		heap.Push(f.timed, h)
+		return
+	}
+	heap.Push(f.ready, h)
+}
+
+// unschedule
+func (f *Frontier) unschedule(h *host) {
+	if h.heap != nil {
+		heap.Remove(h.heap, h.index)
+	}
+}
+
+// forget an idle host, only its last request time is kept.
+func (f *Frontier) forget(h *host) {
+	if len(h.q) == 0 && h.active == 0 {
+		delete(f.hosts, h.name)
+		f.idle.Add(h.name, h.next)
+	}
+}
+
+// policy of a host, the first matching rule wins.
+func (f *Frontier) policy(name string) Policy {
+	p := f.conf.Policy
+
+	for _, r := range f.conf.Rules {
+		if ok, _ := path.Match(strings.ToLower(r.Pattern), name); !ok {
+			continue
+		}
+
+		if r.Policy.Delay > 0 {
+			p.Delay = r.Policy.Delay
+		}
+		if r.Policy.MaxConns > 0 {
+			p.MaxConns = r.Policy.MaxConns
+		}
+		break
+	}
+
+	return p
+}
+
+// hostHeap
+type hostHeap struct {
+	hosts []*host
+	less  func(a, b *host) bool
+}
+
+func (h *hostHeap) Len() int           { return len(h.hosts) }
+func (h *hostHeap) Less(i, j int) bool { return h.less(h.hosts[i], h.hosts[j]) }
+func (h *hostHeap) top() *host         { return h.hosts[0] }
+
+func (h *hostHeap) Swap(i, j int) {
+	h.hosts[i], h.hosts[j] = h.hosts[j], h.hosts[i]
+	h.hosts[i].index = i
+	h.hosts[j].index = j
+}
+
+func (h *hostHeap) Push(x interface{}) {
+	e := x.(*host)
+	e.heap = h
+	e.index = len(h.hosts)
+	h.hosts = append(h.hosts, e)
+}
+
+func (h *hostHeap) Pop() interface{} {
+	n := len(h.hosts)
+	e := h.hosts[n-1]
+	h.hosts[n-1] = nil
+	h.hosts = h.hosts[:n-1]
+	e.heap = nil
+	e.index = -1
+	return e
+}
+
+// reqHeap: best score first, then first queued.
+type reqHeap []*item
+
+func (q reqHeap) Len() int { return len(q) }
+
+func (q reqHeap) Less(i, j int) bool {
+	if q[i].score != q[j].score {
+		return q[i].score > q[j].score
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q reqHeap) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *reqHeap) Push(x interface{}) { *q = append(*q, x.(*item)) }
+
+func (q *reqHeap) Pop() interface{} {
+	old := *q
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return it
+}
+
+// before reports whether the best request of q ranks before the one of o.
+func (q reqHeap) before(o reqHeap) bool {
+	a, b := q[0], o[0]
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.seq < b.seq
+}
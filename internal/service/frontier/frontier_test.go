@@ -0,0 +1,235 @@
+package frontier_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/service/frontier"
+
+	//
+	"github.com/twiny/wbot"
+)
+
+// request
+func request(t *testing.T, link string) wbot.Request {
+	t.Helper()
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wbot.Request{URL: u}
+}
+
+// enqueue
+func enqueue(t *testing.T, f *frontier.Frontier, links ...string) {
+	t.Helper()
+
+	for _, link := range links {
+		if err := f.Enqueue(request(t, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// dequeue returns the url of the next request, or "" if none is ready.
+func dequeue(t *testing.T, f *frontier.Frontier) string {
+	t.Helper()
+
+	req, err := f.Dequeue()
+	switch err {
+	case nil:
+		return req.URL.String()
+	case frontier.ErrEmpty:
+		return ""
+	default:
+		t.Fatal(err)
+		return ""
+	}
+}
+
+// next calls Next, failing if it does not return in time.
+func next(t *testing.T, f *frontier.Frontier) bool {
+	t.Helper()
+
+	done := make(chan bool, 1)
+	go func() { done <- f.Next() }()
+
+	select {
+	case ok := <-done:
+		return ok
+	case <-time.After(2 * time.Second):
+		t.Fatal("Next did not return")
+		return false
+	}
+}
+
+// TestDone: a dequeued request holds a connection of its host until Done.
+func TestDone(t *testing.T) {
+	f := frontier.New(frontier.Config{Workers: 1, MaxDepth: 1})
+	defer f.Close()
+
+	enqueue(t, f, "http://a.test/1", "http://a.test/2")
+
+	if got := dequeue(t, f); got != "http://a.test/1" {
+		t.Fatalf("dequeue = %q, want http://a.test/1", got)
+	}
+	if got := dequeue(t, f); got != "" {
+		t.Fatalf("dequeue while leased = %q, want none", got)
+	}
+
+	// other hosts are not released
+	f.Done("b.test")
+	if got := dequeue(t, f); got != "" {
+		t.Fatalf("dequeue after Done of another host = %q, want none", got)
+	}
+
+	f.Done("A.test")
+	if got := dequeue(t, f); got != "http://a.test/2" {
+		t.Fatalf("dequeue after Done = %q, want http://a.test/2", got)
+	}
+
+	// a second Done does not free a connection twice
+	f.Done("a.test")
+	f.Done("a.test")
+	enqueue(t, f, "http://a.test/3", "http://a.test/4")
+	if got := dequeue(t, f); got != "http://a.test/3" {
+		t.Fatalf("dequeue = %q, want http://a.test/3", got)
+	}
+	if got := dequeue(t, f); got != "" {
+		t.Fatalf("dequeue while leased = %q, want none", got)
+	}
+}
+
+// TestMaxConns
+func TestMaxConns(t *testing.T) {
+	f := frontier.New(frontier.Config{
+		Workers:  1,
+		MaxDepth: 1,
+		Policy:   frontier.Policy{MaxConns: 2},
+		Rules: []frontier.Rule{
+			{Pattern: "*.slow.test", Policy: frontier.Policy{MaxConns: 1}},
+		},
+	})
+	defer f.Close()
+
+	enqueue(t, f,
+		"http://a.test/1", "http://a.test/2", "http://a.test/3",
+		"http://www.slow.test/1", "http://www.slow.test/2",
+	)
+
+	counts := map[string]int{}
+	for link := dequeue(t, f); link != ""; link = dequeue(t, f) {
+		u, _ := url.Parse(link)
+		counts[u.Hostname()]++
+	}
+
+	if counts["a.test"] != 2 || counts["www.slow.test"] != 1 {
+		t.Fatalf("dequeued by host = %v, want a.test 2 and www.slow.test 1", counts)
+	}
+
+	f.Done("a.test")
+	if got := dequeue(t, f); got != "http://a.test/3" {
+		t.Fatalf("dequeue after Done = %q, want http://a.test/3", got)
+	}
+}
+
+// TestBackoff: hosts are held until their backoff, the earliest is fetched first.
+func TestBackoff(t *testing.T) {
+	f := frontier.New(frontier.Config{Workers: 1, MaxDepth: 1})
+	defer f.Close()
+
+	enqueue(t, f, "http://a.test/", "http://b.test/", "http://c.test/")
+
+	now := time.Now()
+	f.Backoff("a.test", now.Add(300*time.Millisecond))
+	f.Backoff("b.test", now.Add(100*time.Millisecond))
+
+	// hosts not known yet keep their backoff until queued
+	f.Backoff("d.test", now.Add(time.Hour))
+	enqueue(t, f, "http://d.test/")
+
+	if got := dequeue(t, f); got != "http://c.test/" {
+		t.Fatalf("dequeue = %q, want http://c.test/", got)
+	}
+	if got := dequeue(t, f); got != "" {
+		t.Fatalf("dequeue during backoff = %q, want none", got)
+	}
+
+	var order []string
+	for len(order) < 2 && next(t, f) {
+		order = append(order, dequeue(t, f))
+	}
+
+	if len(order) != 2 || order[0] != "http://b.test/" || order[1] != "http://a.test/" {
+		t.Fatalf("order = %v, want b.test then a.test", order)
+	}
+	if elapsed := time.Since(now); elapsed < 300*time.Millisecond {
+		t.Fatalf("a.test fetched after %s, before its backoff", elapsed)
+	}
+	if got := dequeue(t, f); got != "" {
+		t.Fatalf("dequeue = %q, want d.test held", got)
+	}
+}
+
+// TestNext: Next returns false once the queue is empty and every worker is idle.
+func TestNext(t *testing.T) {
+	f := frontier.New(frontier.Config{Workers: 1, MaxDepth: 1})
+	defer f.Close()
+
+	enqueue(t, f,
+		"http://a.test/",
+		"http://a.test/deep",
+		"http://a.test/file.pdf",
+	)
+
+	// deeper than max depth
+	deep := request(t, "http://b.test/")
+	deep.Depth = 2
+	if err := f.Enqueue(deep); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for next(t, f) {
+		link := dequeue(t, f)
+		got = append(got, link)
+
+		u, _ := url.Parse(link)
+		f.Done(u.Hostname())
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("fetched %v, want http://a.test/ and http://a.test/deep", got)
+	}
+	if n := f.Len(); n != 0 {
+		t.Fatalf("Len = %d, want 0", n)
+	}
+
+	// an empty frontier stops at once
+	empty := frontier.New(frontier.Config{Workers: 1})
+	defer empty.Close()
+
+	if next(t, empty) {
+		t.Fatal("Next on an empty frontier = true, want false")
+	}
+}
+
+// TestClose: waiting workers stop.
+func TestClose(t *testing.T) {
+	f := frontier.New(frontier.Config{Workers: 2, MaxDepth: 1})
+
+	enqueue(t, f, "http://a.test/")
+	f.Backoff("a.test", time.Now().Add(time.Hour))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		f.Close()
+	}()
+
+	if next(t, f) {
+		t.Fatal("Next after Close = true, want false")
+	}
+}
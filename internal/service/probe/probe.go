@@ -0,0 +1,179 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	//
+	"github.com/twiny/spidy/v2/internal/pkg/guard"
+	"github.com/twiny/spidy/v2/internal/pkg/lru"
+	"github.com/twiny/spidy/v2/internal/pkg/signature"
+
+	//
+	"golang.org/x/net/publicsuffix"
+)
+
+// reasons a link is dead
+const (
+	ReasonNXDomain = "nxdomain" // the domain does not exist
+	ReasonRefused  = "refused"  // connection refused
+	ReasonParked   = "parked"   // parking page
+)
+
+// defaults
+const (
+	defaultTimeout = 10 * time.Second
+	maxBodySize    = 256 * 1024 // of a probed page
+	maxRedirects   = 5
+	maxHosts       = 100000 // probe results kept
+)
+
+// Config
+type Config struct {
+	Timeout   time.Duration // of a probe
+	UserAgent string
+	TTL       time.Duration  // how long a result is kept, 0 for the whole crawl
+	Signs     *signature.Set // parking pages, the built-in ones if nil
+	Guard     *guard.Guard   // optional, rejects connections to reserved ips
+}
+
+// Result
+type Result struct {
+	Dead   bool
+	Reason string
+}
+
+// entry
+type entry struct {
+	res     Result
+	expires time.Time
+}
+
+// Prober checks whether outbound links are dead: their domain does not
+// resolve, their host refuses connections or serves a parking page.
+type Prober struct {
+	conf     Config
+	resolver *net.Resolver
+	client   *http.Client
+	hosts    *lru.Cache[string, entry]
+}
+
+// NewProber
+func NewProber(conf Config) *Prober {
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+
+	if conf.Signs == nil {
+		conf.Signs = signature.Default()
+	}
+
+	return &Prober{
+		conf:     conf,
+		resolver: net.DefaultResolver,
+		client: &http.Client{
+			Transport: conf.Guard.Transport(),
+			Timeout:   conf.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return http.ErrUseLastResponse
+				}
+				return nil
+			},
+		},
+		hosts: lru.New[string, entry](maxHosts),
+	}
+}
+
+// Probe link, results are cached by host.
+func (p *Prober) Probe(ctx context.Context, link string) Result {
+	u, err := url.Parse(link)
+	if err != nil || u.Hostname() == "" {
+		return Result{}
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if e, found := p.hosts.Get(host); found && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		return e.res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.conf.Timeout)
+	defer cancel()
+
+	res := p.probe(ctx, host, u)
+
+	e := entry{res: res}
+	if p.conf.TTL > 0 {
+		e.expires = time.Now().Add(p.conf.TTL)
+	}
+	p.hosts.Add(host, e)
+
+	return res
+}
+
+// probe the registered domain of host then link itself.
+func (p *Prober) probe(ctx context.Context, host string, link *url.URL) Result {
+	if res := p.resolve(ctx, host); res.Dead {
+		return res
+	}
+	return p.http(ctx, link)
+}
+
+// resolve: a removed subdomain of a live domain is not a candidate, so the
+// registered domain is looked up, it always has name servers.
+func (p *Prober) resolve(ctx context.Context, host string) Result {
+	root, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return Result{}
+	}
+
+	if _, err := p.resolver.LookupNS(ctx, root); err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return Result{Dead: true, Reason: ReasonNXDomain}
+		}
+	}
+
+	// timeouts and server failures are inconclusive
+	return Result{}
+}
+
+// http fetches link, it is dead if refused or parked.
+func (p *Prober) http(ctx context.Context, link *url.URL) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link.String(), nil)
+	if err != nil {
+		return Result{}
+	}
+	if p.conf.UserAgent != "" {
+		req.Header.Set("User-Agent", p.conf.UserAgent)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return Result{Dead: true, Reason: ReasonRefused}
+		}
+		return Result{}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return Result{}
+	}
+
+	_, parked := p.conf.Signs.Parked(body)
+	_, sale := p.conf.Signs.ForSale(body)
+	if parked || sale {
+		return Result{Dead: true, Reason: ReasonParked}
+	}
+
+	return Result{}
+}
@@ -0,0 +1,121 @@
+package writer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
+)
+
+// BufferedWriter wraps a spider.Writer and flushes it every `size`
+// writes or every `interval`, whichever comes first. Batch oriented
+// writers can commit their batch on Flush.
+type BufferedWriter struct {
+	l       *sync.Mutex
+	w       spider.Writer
+	size    int
+	pending int
+	done    chan struct{}
+	wg      *sync.WaitGroup
+	closed  bool
+}
+
+// NewBufferedWriter
+func NewBufferedWriter(w spider.Writer, size int, interval time.Duration) *BufferedWriter {
+	b := &BufferedWriter{
+		l:    &sync.Mutex{},
+		w:    w,
+		size: size,
+		done: make(chan struct{}),
+		wg:   &sync.WaitGroup{},
+	}
+
+	if interval > 0 {
+		b.wg.Add(1)
+		go b.tick(interval)
+	}
+
+	return b
+}
+
+// Write
+func (b *BufferedWriter) Write(d *spider.Domain) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	if err := b.w.Write(d); err != nil {
+		return err
+	}
+
+	b.pending++
+	if b.size > 0 && b.pending >= b.size {
+		return b.flush()
+	}
+
+	return nil
+}
+
+// Flush
+func (b *BufferedWriter) Flush() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	return b.flush()
+}
+
+// Close flushes pending writes and closes the underlying writer.
+func (b *BufferedWriter) Close() error {
+	b.l.Lock()
+	if b.closed {
+		b.l.Unlock()
+		return ErrClosed
+	}
+	b.closed = true
+	b.l.Unlock()
+
+	close(b.done)
+	b.wg.Wait()
+
+	b.l.Lock()
+	err := b.flush()
+	b.l.Unlock()
+
+	if cerr := b.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// flush
+func (b *BufferedWriter) flush() error {
+	if b.pending == 0 {
+		return nil
+	}
+	b.pending = 0
+	return b.w.Flush()
+}
+
+// tick
+func (b *BufferedWriter) tick(interval time.Duration) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			b.Flush()
+		}
+	}
+}
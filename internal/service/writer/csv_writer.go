@@ -1,58 +1,350 @@
 package writer
 
 import (
+	"bufio"
+	"compress/gzip"
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/twiny/spidy/v2/internal/pkg/spider/v1"
 )
 
+// rotation modes
+const (
+	RotateNone = ""
+	RotateDate = "date"
+	RotateSize = "size"
+)
+
+const dateLayout = "2006-01-02"
+
+// CSVConfig
+type CSVConfig struct {
+	Dir      string
+	Filename string // template: {date}, {time}
+	Columns  []string
+	Rotate   string // "date", "size" or "" to disable
+	MaxSize  int64  // used by size rotation
+	Compress bool   // gzip rotated files
+}
+
 // CSVWriter
 type CSVWriter struct {
-	l *sync.Mutex
-	f *os.File
-	w *csv.Writer
+	l    *sync.Mutex
+	wg   *sync.WaitGroup
+	conf CSVConfig
+	fp   string // current file path
+	date string // date current file was opened
+	f    *os.File
+	c    *counter
+	w    *csv.Writer
+	cerr error // first failed compression, returned by Flush or Close
 }
 
 // NewCSVWriter
-func NewCSVWriter(dir string) (*CSVWriter, error) {
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+func NewCSVWriter(conf CSVConfig) (*CSVWriter, error) {
+	if err := spider.ValidColumns(conf.Columns); err != nil {
+		return nil, err
+	}
+
+	switch conf.Rotate {
+	case RotateNone, RotateDate:
+	case RotateSize:
+		if conf.MaxSize <= 0 {
+			return nil, fmt.Errorf("size rotation requires a max size")
+		}
+	default:
+		return nil, fmt.Errorf("unknown rotation %q", conf.Rotate)
+	}
+
+	if _, err := os.Stat(conf.Dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(conf.Dir, 0755); err != nil {
 			return nil, err
 		}
 	}
 
-	name := time.Now().Format("2006-01-02")
-	fp := filepath.Join(dir, name+"_domains.csv")
+	c := &CSVWriter{
+		l:    &sync.Mutex{},
+		wg:   &sync.WaitGroup{},
+		conf: conf,
+	}
 
-	// open or create log
-	f, err := os.OpenFile(fp, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
-	if err != nil {
+	if err := c.open(time.Now()); err != nil {
 		return nil, err
 	}
 
-	return &CSVWriter{
-		l: &sync.Mutex{},
-		f: f,
-		w: csv.NewWriter(f),
-	}, nil
+	return c, nil
 }
 
 // Write
 func (c *CSVWriter) Write(d *spider.Domain) error {
 	c.l.Lock()
-	defer func() {
-		c.l.Unlock()
+	defer c.l.Unlock()
+
+	if err := c.rotate(time.Now()); err != nil {
+		return err
+	}
+
+	return c.w.Write(d.Row(c.conf.Columns))
+}
+
+// Flush
+func (c *CSVWriter) Flush() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return err
+	}
+
+	return c.compressErr()
+}
+
+// Close waits for pending compressions.
+func (c *CSVWriter) Close() error {
+	err := c.Flush()
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+
+	c.wg.Wait()
+
+	c.l.Lock()
+	if cerr := c.compressErr(); err == nil {
+		err = cerr
+	}
+	c.l.Unlock()
+
+	return err
+}
+
+// compressErr returns the first failed compression once, l must be held.
+func (c *CSVWriter) compressErr() error {
+	err := c.cerr
+	c.cerr = nil
+	return err
+}
+
+// open or create the result file for t and write the header if the
+// file is new. a file written with other columns is moved out of the
+// way as on rotation, rows of both layouts are never mixed.
+func (c *CSVWriter) open(t time.Time) error {
+	fp := filepath.Join(c.conf.Dir, filename(c.conf.Filename, t))
+
+	same, err := sameHeader(fp, c.conf.Columns)
+	if err != nil {
+		return err
+	}
+	if !same {
+		old := nextFree(fp)
+		if err := os.Rename(fp, old); err != nil {
+			return err
+		}
+		c.archive(old)
+	}
+
+	f, err := os.OpenFile(fp, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	c.fp = fp
+	c.date = t.Format(dateLayout)
+	c.f = f
+	c.c = &counter{w: f, n: info.Size()}
+	c.w = csv.NewWriter(c.c)
+
+	// header once per file
+	if info.Size() == 0 {
+		if err := c.w.Write(c.conf.Columns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rotate the current file if needed.
+func (c *CSVWriter) rotate(t time.Time) error {
+	switch c.conf.Rotate {
+	case RotateDate:
+		if t.Format(dateLayout) == c.date {
+			return nil
+		}
+	case RotateSize:
+		// rows buffered by the csv writer are counted once written
 		c.w.Flush()
+		if err := c.w.Error(); err != nil {
+			return err
+		}
+		if c.c.n < c.conf.MaxSize {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return err
+	}
+
+	if err := c.f.Close(); err != nil {
+		return err
+	}
+
+	// the new file would reuse the same name,
+	// move the old one out of the way.
+	old := c.fp
+	if filepath.Join(c.conf.Dir, filename(c.conf.Filename, t)) == old {
+		next := nextFree(old)
+		if err := os.Rename(old, next); err != nil {
+			return err
+		}
+		old = next
+	}
+
+	c.archive(old)
+
+	return c.open(t)
+}
+
+// archive a file moved out of the way, it is compressed if configured.
+func (c *CSVWriter) archive(old string) {
+	if !c.conf.Compress {
+		return
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		if err := compress(old); err != nil {
+			c.l.Lock()
+			if c.cerr == nil {
+				c.cerr = fmt.Errorf("compress %s: %w", old, err)
+			}
+			c.l.Unlock()
+		}
 	}()
+}
+
+// sameHeader reports whether the result file fp is missing, empty or
+// has columns as header.
+func sameHeader(fp string, columns []string) (bool, error) {
+	f, err := os.Open(fp)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	if line == "" {
+		return true, nil
+	}
+
+	header, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return false, nil
+	}
+
+	if len(header) != len(columns) {
+		return false, nil
+	}
+	for i := range header {
+		if header[i] != columns[i] {
+			return false, nil
+		}
+	}
 
-	return c.w.Write([]string{d.Name + "." + d.TLD, d.Status})
+	return true, nil
 }
 
-// Close
-func (c *CSVWriter) Close() error {
-	return c.f.Close()
+// filename
+func filename(tmpl string, t time.Time) string {
+	return strings.NewReplacer(
+		"{date}", t.Format(dateLayout),
+		"{time}", t.Format("150405"),
+	).Replace(tmpl)
+}
+
+// nextFree returns the first unused `name.N.ext` for fp.
+func nextFree(fp string) string {
+	ext := filepath.Ext(fp)
+	base := strings.TrimSuffix(fp, ext)
+
+	for i := 1; ; i++ {
+		next := fmt.Sprintf("%s.%d%s", base, i, ext)
+		if exists(next) || exists(next+".gz") {
+			continue
+		}
+		return next
+	}
+}
+
+// exists
+func exists(fp string) bool {
+	_, err := os.Stat(fp)
+	return err == nil
+}
+
+// compress fp into fp.gz and remove fp, fp is kept if it fails.
+func compress(fp string) error {
+	src, err := os.Open(fp)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(fp+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fp + ".gz")
+		return err
+	}
+
+	return os.Remove(fp)
+}
+
+// counter counts bytes written to w.
+type counter struct {
+	w io.Writer
+	n int64
+}
+
+// Write
+func (c *counter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
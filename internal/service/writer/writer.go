@@ -0,0 +1,8 @@
+package writer
+
+import "errors"
+
+// Errs
+var (
+	ErrClosed = errors.New("writer is closed")
+)